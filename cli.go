@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+
+	"github.com/lian/gonky/config"
+	"github.com/lian/gonky/widgets"
+	"github.com/lian/gonky/widgets/format"
+	"github.com/lian/gonky/widgets/status"
+)
+
+const usage = `usage: gonky [flags] [command]
+
+commands:
+  run           open the gonky window (default)
+  snapshot      print the status bar once
+  dump          print the current stats as JSON
+  check-config  validate the config file
+
+flags:
+`
+
+// options are the command line flags.
+type options struct {
+	config   string
+	monitor  string
+	windowed geometry
+	verbose  bool
+}
+
+// geometry is a window size and position like "800x600+10+20". The
+// position is relative to the monitor and optional.
+type geometry struct {
+	Width, Height int
+	X, Y          int
+	set           bool
+}
+
+var geometryPattern = regexp.MustCompile(`^(\d+)x(\d+)(?:([+-]\d+)([+-]\d+))?$`)
+
+func (g *geometry) String() string {
+	if !g.set {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d%+d%+d", g.Width, g.Height, g.X, g.Y)
+}
+
+func (g *geometry) Set(value string) error {
+	m := geometryPattern.FindStringSubmatch(value)
+	if m == nil {
+		return fmt.Errorf("want WxH+X+Y, got %q", value)
+	}
+	g.Width, _ = strconv.Atoi(m[1])
+	g.Height, _ = strconv.Atoi(m[2])
+	g.X, g.Y = 0, 0
+	if m[3] != "" {
+		g.X, _ = strconv.Atoi(m[3])
+		g.Y, _ = strconv.Atoi(m[4])
+	}
+	if g.Width == 0 || g.Height == 0 {
+		return fmt.Errorf("window size must not be zero, got %q", value)
+	}
+	g.set = true
+	return nil
+}
+
+var commands = map[string]func(options) error{
+	"run":          run,
+	"snapshot":     snapshot,
+	"dump":         dump,
+	"check-config": checkConfig,
+}
+
+// parseArgs parses the flags and the command. Flags may come before and
+// after the command. Errors are reported to output along with the usage.
+func parseArgs(args []string, output io.Writer) (options, string, error) {
+	opts := options{}
+	flags := flag.NewFlagSet("gonky", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&opts.config, "config", config.DefaultPath(), "config `file`")
+	flags.StringVar(&opts.monitor, "monitor", "", "monitor to open the window on, by `index or name`")
+	flags.Var(&opts.windowed, "windowed", "window size and position on the monitor as `WxH+X+Y` instead of the whole monitor")
+	flags.BoolVar(&opts.verbose, "verbose", false, "log every collection")
+	flags.Usage = func() {
+		fmt.Fprint(output, usage)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return opts, "", err
+	}
+	command := "run"
+	if flags.NArg() > 0 {
+		command = flags.Arg(0)
+		if err := flags.Parse(flags.Args()[1:]); err != nil {
+			return opts, "", err
+		}
+		if flags.NArg() > 0 {
+			err := fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+			fmt.Fprintln(output, err)
+			flags.Usage()
+			return opts, "", err
+		}
+	}
+	if _, ok := commands[command]; !ok {
+		err := fmt.Errorf("unknown command %q", command)
+		fmt.Fprintln(output, err)
+		flags.Usage()
+		return opts, "", err
+	}
+	return opts, command, nil
+}
+
+// selectMonitor finds a monitor by its index in glfw.GetMonitors or by its
+// name. An empty name gives the primary monitor.
+func selectMonitor(name string) (*glfw.Monitor, error) {
+	if name == "" {
+		return glfw.GetPrimaryMonitor(), nil
+	}
+	monitors := glfw.GetMonitors()
+	if i, err := strconv.Atoi(name); err == nil {
+		if i < 0 || i >= len(monitors) {
+			return nil, fmt.Errorf("monitor %d not found, there are %d", i, len(monitors))
+		}
+		return monitors[i], nil
+	}
+	var names []string
+	for _, m := range monitors {
+		if m.GetName() == name {
+			return m, nil
+		}
+		names = append(names, m.GetName())
+	}
+	return nil, fmt.Errorf("monitor %q not found, have %s", name, strings.Join(names, ", "))
+}
+
+// collectOnce loads the config and collects twice, a second apart, so
+// the rates have a value.
+func collectOnce(opts options) (*config.Config, *widgets.Stats, error) {
+	cfg, err := config.Load(opts.config)
+	if err != nil {
+		return nil, nil, err
+	}
+	widgets.SeriesCapacity = cfg.SeriesCapacity
+	applySettings(cfg)
+
+	stats := widgets.NewStats()
+	applyCollectors(cfg, stats)
+	stats.Collect()
+	time.Sleep(time.Second)
+	stats.Collect()
+	return cfg, stats, nil
+}
+
+// snapshot prints the status bar, with the sections of a configured
+// format separated by tabs.
+func snapshot(opts options) error {
+	cfg, stats, err := collectOnce(opts)
+	if err != nil {
+		return err
+	}
+	if cfg.Status.Format == "" {
+		fmt.Println(status.Text(stats.Snapshot()))
+		return nil
+	}
+	env := &format.Env{Sample: stats.Snapshot(), Now: time.Now(), NetworkNames: cfg.Network.Names}
+	sections := format.MustParse(cfg.Status.Format).Execute(env)
+	fmt.Println(strings.Join(sections[:], "\t"))
+	return nil
+}
+
+func dump(opts options) error {
+	_, stats, err := collectOnce(opts)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(stats.Snapshot())
+}
+
+func checkConfig(opts options) error {
+	if _, err := config.Load(opts.config); err != nil {
+		return err
+	}
+	if _, err := os.Stat(opts.config); errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("%s does not exist, using the defaults\n", opts.config)
+		return nil
+	}
+	fmt.Printf("%s is valid\n", opts.config)
+	return nil
+}
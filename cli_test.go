@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args    []string
+		command string
+		opts    options
+	}{
+		{nil, "run", options{}},
+		{[]string{"dump"}, "dump", options{}},
+		{[]string{"-config", "a.toml", "check-config"}, "check-config", options{config: "a.toml"}},
+		// flags after the command
+		{[]string{"snapshot", "-config", "b.toml", "-verbose"}, "snapshot", options{config: "b.toml", verbose: true}},
+		{[]string{"-monitor", "HDMI-1", "-windowed", "800x600+10-20"}, "run", options{monitor: "HDMI-1", windowed: geometry{800, 600, 10, -20, true}}},
+	}
+	for _, test := range tests {
+		var output bytes.Buffer
+		opts, command, err := parseArgs(test.args, &output)
+		if err != nil {
+			t.Errorf("%q: %v", test.args, err)
+			continue
+		}
+		if test.opts.config == "" {
+			test.opts.config = opts.config
+		}
+		if command != test.command || opts != test.opts {
+			t.Errorf("%q: %s with %+v, want %s with %+v", test.args, command, opts, test.command, test.opts)
+		}
+		if output.Len() != 0 {
+			t.Errorf("%q: printed %q", test.args, output.String())
+		}
+	}
+}
+
+func TestParseArgsErrors(t *testing.T) {
+	tests := []struct {
+		args   []string
+		output string
+	}{
+		{[]string{"-fullscreen"}, "flag provided but not defined: -fullscreen"},
+		{[]string{"dump", "-fullscreen"}, "flag provided but not defined: -fullscreen"},
+		{[]string{"draw"}, `unknown command "draw"`},
+		{[]string{"dump", "now"}, "unexpected arguments: now"},
+		{[]string{"-windowed", "800"}, `want WxH+X+Y, got "800"`},
+	}
+	for _, test := range tests {
+		var output bytes.Buffer
+		if _, _, err := parseArgs(test.args, &output); err == nil {
+			t.Errorf("%q: no error", test.args)
+		}
+		if !strings.Contains(output.String(), test.output) || !strings.Contains(output.String(), "usage: gonky") {
+			t.Errorf("%q: printed %q, want %q and the usage", test.args, output.String(), test.output)
+		}
+	}
+}
+
+func TestGeometrySet(t *testing.T) {
+	tests := []struct {
+		value string
+		want  geometry
+		ok    bool
+	}{
+		{"800x600", geometry{800, 600, 0, 0, true}, true},
+		{"800x600+10+20", geometry{800, 600, 10, 20, true}, true},
+		{"1920x1080-0+1080", geometry{1920, 1080, 0, 1080, true}, true},
+		{"800x600+10", geometry{}, false},
+		{"800X600", geometry{}, false},
+		{"0x600", geometry{}, false},
+		{"800x600 ", geometry{}, false},
+		{"-800x600", geometry{}, false},
+		{"", geometry{}, false},
+	}
+	for _, test := range tests {
+		var g geometry
+		err := g.Set(test.value)
+		if (err == nil) != test.ok {
+			t.Errorf("Set(%q): error %v", test.value, err)
+			continue
+		}
+		if test.ok && g != test.want {
+			t.Errorf("Set(%q) = %+v, want %+v", test.value, g, test.want)
+		}
+	}
+
+	g := geometry{}
+	if g.String() != "" {
+		t.Errorf("unset geometry is %q", g.String())
+	}
+	g.Set("800x600-5+7")
+	if g.String() != "800x600-5+7" {
+		t.Errorf("String() = %q, want 800x600-5+7", g.String())
+	}
+}
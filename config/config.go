@@ -0,0 +1,350 @@
+// Package config reads the gonky configuration file, a TOML file that
+// declares the widgets, their layout and style, and the collectors.
+//
+// A missing file is the same as an empty one: every setting has a default
+// matching the built-in layout.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/lian/gonky/widgets"
+	"github.com/lian/gonky/widgets/format"
+)
+
+// Config is the whole configuration file.
+type Config struct {
+	Status  Status  `toml:"status"`
+	Network Network `toml:"network"`
+	Disk    Disk    `toml:"disk"`
+	Thermal Thermal `toml:"thermal"`
+	// SeriesCapacity is the number of raw values every metric keeps.
+	SeriesCapacity int `toml:"series_capacity"`
+	// Collectors is keyed by collector name, like "cpu" or "network".
+	Collectors map[string]Collector `toml:"collectors"`
+	// Widgets are drawn in order. Without any [[widget]] table the
+	// built-in layout is used.
+	Widgets []Widget `toml:"widget"`
+}
+
+// Status holds the settings of the status bar at the top of the window.
+type Status struct {
+	FontPadding    int    `toml:"font_padding"`
+	ShowLoad       bool   `toml:"show_load"`
+	ShowPressure   bool   `toml:"show_pressure"`
+	ShowDisks      bool   `toml:"show_disks"`
+	ShowCpuCores   bool   `toml:"show_cpu_cores"`
+	ShowWifiSignal bool   `toml:"show_wifi_signal"`
+	Background     string `toml:"background"`
+	Foreground     string `toml:"foreground"`
+	// Format is a template replacing the built-in layout, see the
+	// widgets/format package.
+	Format string `toml:"format"`
+}
+
+type Network struct {
+	// Names maps interface names to the aliases shown instead.
+	Names map[string]string `toml:"names"`
+}
+
+type Disk struct {
+	// Mounts are the mount points whose space usage is collected. Their
+	// series are named after widgets.FilesystemName, "fs.root.used" for
+	// "/" and "fs.home.used" for "/home".
+	Mounts []string `toml:"mounts"`
+}
+
+type Thermal struct {
+	// Source selects the temperature the thermal widget shows: "max",
+	// "average", a sensor key like "hwmon2/nvme/Composite", a sensor name
+	// like "k10temp/Tctl" or a chip name like "coretemp".
+	Source string `toml:"source"`
+}
+
+// Collector overrides the defaults of a collector. A zero Interval keeps
+// the collector's own.
+type Collector struct {
+	Disabled bool     `toml:"disabled"`
+	Interval Duration `toml:"interval"`
+}
+
+// Widget kinds.
+const (
+	WidgetThermal = "thermal"
+	WidgetGraph   = "graph"
+	WidgetNetwork = "network"
+	WidgetTop     = "top"
+)
+
+// Widget places one widget. X and Y are its top left corner in pixels
+// from the top left of the window. The status bar takes the top 18.
+type Widget struct {
+	Type   string  `toml:"type"`
+	X      float64 `toml:"x"`
+	Y      float64 `toml:"y"`
+	Width  float64 `toml:"width"`
+	Height float64 `toml:"height"`
+
+	// Background and Foreground are colours like "#333333".
+	Background string `toml:"background"`
+	Foreground string `toml:"foreground"`
+
+	// Metrics are the series patterns a graph draws, like "disk.*.read".
+	Metrics []string `toml:"metrics"`
+	// Format is how a graph formats values: "number", "percent",
+	// "bytes" or "rate" for bytes per second.
+	Format    string   `toml:"format"`
+	Stacked   bool     `toml:"stacked"`
+	MaxMetric string   `toml:"max_metric"`
+	RowHeight float64  `toml:"row_height"`
+	Range     Duration `toml:"range"`
+
+	// Count is the number of processes the top widget lists. Its width
+	// and height are optional, it fits the rows without them.
+	Count int `toml:"count"`
+	// HideCpuCores and HideFrequency turn off parts of the thermal widget.
+	HideCpuCores  bool `toml:"hide_cpu_cores"`
+	HideFrequency bool `toml:"hide_frequency"`
+}
+
+// Duration is a time.Duration written like "5s" or "1h30m".
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// DefaultPath returns $XDG_CONFIG_HOME/gonky/config.toml, falling back to
+// ~/.config when XDG_CONFIG_HOME is not set.
+func DefaultPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "gonky", "config.toml")
+}
+
+// Default returns the built-in configuration.
+func Default() *Config {
+	return &Config{
+		Status: Status{
+			FontPadding:    3,
+			ShowLoad:       true,
+			ShowPressure:   true,
+			ShowDisks:      true,
+			ShowWifiSignal: true,
+			Background:     "#cccccc",
+			Foreground:     "#000000",
+		},
+		Disk:           Disk{Mounts: []string{"/"}},
+		Thermal:        Thermal{Source: widgets.ThermalMax},
+		SeriesCapacity: 60,
+		Collectors:     map[string]Collector{},
+	}
+}
+
+// DefaultNetworkNames are the interface aliases used when the file has no
+// [network.names] table.
+func DefaultNetworkNames() map[string]string {
+	return map[string]string{
+		"enp0s25": "lan",
+		"wlp3s0":  "wifi",
+	}
+}
+
+// DefaultWidgets is the built-in layout.
+func DefaultWidgets() []Widget {
+	return []Widget{
+		{Type: WidgetThermal, X: 20, Y: 36, Width: 300, Height: 200},
+		{Type: WidgetGraph, X: 20, Y: 256, Width: 300, Height: 100, Metrics: []string{"disk.*.read", "disk.*.write"}, Format: "rate"},
+		{Type: WidgetGraph, X: 340, Y: 256, Width: 300, Height: 100, Metrics: []string{"net.*.rx", "net.*.tx"}, Format: "rate"},
+		{Type: WidgetNetwork, X: 660, Y: 256, Width: 340, Height: 100},
+		{Type: WidgetGraph, X: 20, Y: 368, Width: 300, Height: 40, Metrics: []string{"memory.used", "memory.buffers", "memory.cached"}, Format: "bytes", Stacked: true, MaxMetric: "memory.total"},
+		{Type: WidgetTop, X: 340, Y: 36, Count: 10},
+	}
+}
+
+// Load reads the configuration at path on top of the defaults. A missing
+// file gives the defaults.
+func Load(path string) (*Config, error) {
+	c := Default()
+	md, err := toml.DecodeFile(path, c)
+	if errors.Is(err, os.ErrNotExist) {
+		c.Network.Names = DefaultNetworkNames()
+		c.Widgets = DefaultWidgets()
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, key := range undecoded {
+			keys[i] = key.String()
+		}
+		return nil, fmt.Errorf("%s: unknown keys: %s", path, strings.Join(keys, ", "))
+	}
+	if c.Network.Names == nil {
+		c.Network.Names = DefaultNetworkNames()
+	}
+	if len(c.Widgets) == 0 {
+		c.Widgets = DefaultWidgets()
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Validate checks the settings that decoding alone does not.
+func (c *Config) Validate() error {
+	if c.SeriesCapacity < 1 {
+		return fmt.Errorf("series_capacity must be at least 1")
+	}
+	if c.Status.FontPadding < 0 {
+		return fmt.Errorf("status.font_padding must not be negative")
+	}
+	for _, value := range []string{c.Status.Background, c.Status.Foreground} {
+		if _, err := ParseColor(value); err != nil {
+			return fmt.Errorf("status: %v", err)
+		}
+	}
+	if c.Status.Format != "" {
+		if _, err := format.Parse(c.Status.Format); err != nil {
+			return fmt.Errorf("status.format: %v", err)
+		}
+	}
+	for _, mount := range c.Disk.Mounts {
+		if !path.IsAbs(mount) {
+			return fmt.Errorf("disk.mounts: %q is not an absolute path", mount)
+		}
+	}
+	if err := validateThermalSource(c.Thermal.Source); err != nil {
+		return fmt.Errorf("thermal.source: %v", err)
+	}
+	for name, collector := range c.Collectors {
+		if !isCollector(name) {
+			return fmt.Errorf("collectors.%s: unknown collector, have %s", name, strings.Join(widgets.RegisteredCollectors(), ", "))
+		}
+		if collector.Interval.Duration < 0 {
+			return fmt.Errorf("collectors.%s.interval must not be negative", name)
+		}
+	}
+	for i, w := range c.Widgets {
+		if err := w.validate(); err != nil {
+			return fmt.Errorf("widget %d (%s): %v", i+1, w.Type, err)
+		}
+	}
+	return nil
+}
+
+// validateThermalSource checks the form of a thermal source. Whether the
+// sensor exists is only known to the collector, on the machine it runs on.
+func validateThermalSource(source string) error {
+	if source == widgets.ThermalMax || source == widgets.ThermalAverage {
+		return nil
+	}
+	parts := strings.Split(source, "/")
+	if len(parts) > 3 {
+		return fmt.Errorf("%q has too many parts, want %s, %s or a sensor like \"chip/label\"", source, widgets.ThermalMax, widgets.ThermalAverage)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return fmt.Errorf("%q is not a sensor, want %s, %s or a sensor like \"chip/label\"", source, widgets.ThermalMax, widgets.ThermalAverage)
+		}
+	}
+	return nil
+}
+
+func isCollector(name string) bool {
+	for _, registered := range widgets.RegisteredCollectors() {
+		if registered == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Widget) validate() error {
+	switch w.Type {
+	case WidgetThermal, WidgetNetwork:
+		if w.Width <= 0 || w.Height <= 0 {
+			return fmt.Errorf("width and height are required")
+		}
+	case WidgetGraph:
+		if w.Width <= 0 || w.Height <= 0 {
+			return fmt.Errorf("width and height are required")
+		}
+		if len(w.Metrics) == 0 {
+			return fmt.Errorf("metrics are required")
+		}
+		for _, pattern := range append([]string{w.MaxMetric}, w.Metrics...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("metric %q: %v", pattern, err)
+			}
+		}
+		switch w.Format {
+		case "", "number", "percent", "bytes", "rate":
+		default:
+			return fmt.Errorf("unknown format %q", w.Format)
+		}
+	case WidgetTop:
+		if w.Count < 0 {
+			return fmt.Errorf("count must not be negative")
+		}
+		if w.Width < 0 || w.Height < 0 {
+			return fmt.Errorf("width and height must not be negative")
+		}
+	case "":
+		return fmt.Errorf("type is required")
+	default:
+		return fmt.Errorf("unknown type %q", w.Type)
+	}
+	for _, value := range []string{w.Background, w.Foreground} {
+		if _, err := ParseColor(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseColor parses "#rrggbb" or "#rrggbbaa". An empty string gives the
+// zero colour, which widgets take as "keep the default".
+func ParseColor(value string) (color.RGBA, error) {
+	if value == "" {
+		return color.RGBA{}, nil
+	}
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) != 6 && len(hex) != 8 || hex == value {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q, want #rrggbb", value)
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q, want #rrggbb", value)
+	}
+	return color.RGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
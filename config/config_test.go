@@ -0,0 +1,91 @@
+package config
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadExample(t *testing.T) {
+	got, err := Load("example.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Load(filepath.Join(t.TempDir(), "missing.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the example spells out the defaults, its only override is the
+	// memory interval the collector has anyway
+	want.Collectors = map[string]Collector{"memory": {Interval: Duration{10 * time.Second}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("example.toml loads as\n%+v\nwant the defaults\n%+v", got, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"unknown key", "[status]\nfont_size = 3\n", "unknown keys: status.font_size"},
+		{"unknown widget key", "[[widget]]\ntype = \"top\"\ncolumns = 2\n", "unknown keys: widget.columns"},
+		{"bad colour", "[status]\nbackground = \"#ccc\"\n", `invalid colour "#ccc"`},
+		{"bad widget colour", "[[widget]]\ntype = \"top\"\nforeground = \"red\"\n", `widget 1 (top): invalid colour "red"`},
+		{"unknown collector", "[collectors.gpu]\ninterval = \"5s\"\n", "collectors.gpu: unknown collector"},
+		{"negative interval", "[collectors.cpu]\ninterval = \"-5s\"\n", "collectors.cpu.interval must not be negative"},
+		{"bad interval", "[collectors.cpu]\ninterval = \"5 seconds\"\n", "interval"},
+		{"thermal source", "[thermal]\nsource = \"coretemp//Core 0\"\n", "thermal.source:"},
+		{"relative mount", "[disk]\nmounts = [\"home\"]\n", `disk.mounts: "home"`},
+		{"graph without metrics", "[[widget]]\ntype = \"graph\"\nwidth = 10\nheight = 10\n", "widget 1 (graph): metrics are required"},
+		{"negative top size", "[[widget]]\ntype = \"top\"\nwidth = -1\n", "widget 1 (top): width and height must not be negative"},
+		{"unknown widget", "[[widget]]\ntype = \"clock\"\n", `unknown type "clock"`},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Load(path)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: error %v, want %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestValidateTop(t *testing.T) {
+	c := Default()
+	c.Widgets = []Widget{{Type: WidgetTop, Count: 5}, {Type: WidgetTop, Width: 600, Height: 300}}
+	if err := c.Validate(); err != nil {
+		t.Errorf("top widgets with and without a size: %v", err)
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		value string
+		want  color.RGBA
+		ok    bool
+	}{
+		{"", color.RGBA{}, true},
+		{"#336699", color.RGBA{0x33, 0x66, 0x99, 0xff}, true},
+		{"#33669980", color.RGBA{0x33, 0x66, 0x99, 0x80}, true},
+		{"#ABCDEF", color.RGBA{0xab, 0xcd, 0xef, 0xff}, true},
+		{"336699", color.RGBA{}, false},
+		{"#369", color.RGBA{}, false},
+		{"#33669", color.RGBA{}, false},
+		{"#3366zz", color.RGBA{}, false},
+		{"#+33669", color.RGBA{}, false},
+	}
+	for _, test := range tests {
+		got, err := ParseColor(test.value)
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("ParseColor(%q) = %v, %v, want %v", test.value, got, err, test.want)
+		}
+	}
+}
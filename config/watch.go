@@ -0,0 +1,62 @@
+package config
+
+import (
+	"bytes"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// Watch sends on the returned channel whenever the file at path was
+// written. It watches the directory rather than the file itself, so it
+// keeps working when an editor replaces the file by renaming a new one
+// over it, and when the file does not exist yet. Changes that happen while
+// the last one was not received yet are coalesced.
+func Watch(path string) (<-chan bool, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	dir, name := filepath.Split(filepath.Clean(path))
+	if dir == "" {
+		dir = "."
+	}
+	if _, err := syscall.InotifyAddWatch(fd, dir, syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	changed := make(chan bool, 1)
+	go func() {
+		defer syscall.Close(fd)
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := syscall.Read(fd, buf)
+			if err == syscall.EINTR {
+				continue
+			}
+			if err != nil || n <= 0 {
+				close(changed)
+				return
+			}
+
+			for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				start := offset + syscall.SizeofInotifyEvent
+				end := start + int(event.Len)
+				offset = end
+				if end > n {
+					break
+				}
+				if string(bytes.TrimRight(buf[start:end], "\x00")) != name {
+					continue
+				}
+				select {
+				case changed <- true:
+				default:
+				}
+			}
+		}
+	}()
+	return changed, nil
+}
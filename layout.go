@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"github.com/lian/gonky/config"
+	"github.com/lian/gonky/texture"
+	"github.com/lian/gonky/widgets"
+	"github.com/lian/gonky/widgets/format"
+	"github.com/lian/gonky/widgets/graph"
+	"github.com/lian/gonky/widgets/network"
+	"github.com/lian/gonky/widgets/status"
+	"github.com/lian/gonky/widgets/thermal"
+	"github.com/lian/gonky/widgets/top"
+)
+
+// widget is a placed widget as the main loop sees it.
+type widget struct {
+	texture *texture.Texture
+	render  func()
+	// redraw is set for widgets that update on their own schedule
+	// instead of on stats.Updated, run and stop start and end it.
+	redraw chan bool
+	run    func()
+	stop   func()
+
+	done   chan struct{}
+	closed bool
+}
+
+// screen is the status bar and the widgets of one configuration.
+type screen struct {
+	status  *status.Status
+	widgets []*widget
+}
+
+// newScreen builds the screen of a configuration and applies its
+// settings. Nothing is changed when it fails.
+func newScreen(cfg *config.Config, stats *widgets.Stats, windowWidth, windowHeight int) (*screen, error) {
+	placed, err := buildWidgets(cfg, stats, windowHeight)
+	if err != nil {
+		return nil, err
+	}
+	// the status bar height depends on the font padding
+	applySettings(cfg)
+	return &screen{status: newStatus(cfg, stats, windowWidth, windowHeight), widgets: placed}, nil
+}
+
+// start runs the widgets that update on their own. Their redraw requests
+// are forwarded to redraws until the screen is closed.
+func (s *screen) start(redraws chan<- *widget) {
+	go s.status.Run()
+	for _, w := range s.widgets {
+		if w.redraw == nil {
+			continue
+		}
+		w.done = make(chan struct{})
+		go w.run()
+		go func(w *widget) {
+			for {
+				select {
+				case <-w.redraw:
+				case <-w.done:
+					return
+				}
+				select {
+				case redraws <- w:
+				case <-w.done:
+					return
+				}
+			}
+		}(w)
+	}
+}
+
+// render redraws everything that shows stats.
+func (s *screen) render() {
+	s.status.Render()
+	for _, w := range s.widgets {
+		if w.redraw == nil {
+			w.render()
+		}
+	}
+}
+
+func (s *screen) draw() {
+	s.status.Texture.Draw()
+	for _, w := range s.widgets {
+		w.texture.Draw()
+	}
+}
+
+// close stops the widgets and frees their textures. It has to be called
+// from the thread owning the GL context.
+func (s *screen) close() {
+	s.status.Stop()
+	s.status.Texture.Delete()
+	for _, w := range s.widgets {
+		if w.done != nil {
+			w.stop()
+			close(w.done)
+		}
+		w.closed = true
+		w.texture.Delete()
+	}
+}
+
+// applySettings sets the package level settings of the configuration.
+// widgets.SeriesCapacity is left alone: it only applies to new series and
+// is read by the collectors, so it is set once at startup.
+func applySettings(cfg *config.Config) {
+	status.FontPadding = cfg.Status.FontPadding
+	status.ShowLoad = cfg.Status.ShowLoad
+	status.ShowPressure = cfg.Status.ShowPressure
+	status.ShowDisks = cfg.Status.ShowDisks
+	status.ShowCpuCores = cfg.Status.ShowCpuCores
+	status.ShowWifiSignal = cfg.Status.ShowWifiSignal
+	status.NetworkNamesMap = cfg.Network.Names
+}
+
+// applyCollectors sets the collector intervals, disables collectors and
+// sets the mount points of the disk collector and the thermal source.
+func applyCollectors(cfg *config.Config, stats *widgets.Stats) {
+	for _, c := range stats.Collectors {
+		switch c := c.(type) {
+		case *widgets.DiskCollector:
+			c.SetMounts(cfg.Disk.Mounts)
+		case *widgets.ThermalCollector:
+			c.SetSource(cfg.Thermal.Source)
+		}
+	}
+
+	intervals := map[string]time.Duration{}
+	disabled := map[string]bool{}
+	for name, c := range cfg.Collectors {
+		intervals[name] = c.Interval.Duration
+		disabled[name] = c.Disabled
+	}
+	stats.Schedule(intervals, disabled)
+}
+
+func newStatus(cfg *config.Config, stats *widgets.Stats, windowWidth, windowHeight int) *status.Status {
+	s := status.New(windowWidth, windowHeight, program, stats)
+	setColor(&s.Background, cfg.Status.Background)
+	setColor(&s.Foreground, cfg.Status.Foreground)
+	if cfg.Status.Format != "" {
+		// validated with the config
+		s.Template = format.MustParse(cfg.Status.Format)
+	}
+	return s
+}
+
+// buildWidgets creates the widgets of the configuration. The config
+// places widgets from the top of the window, textures from the bottom.
+func buildWidgets(cfg *config.Config, stats *widgets.Stats, windowHeight int) ([]*widget, error) {
+	var built []*widget
+	for i, w := range cfg.Widgets {
+		x, y := w.X, float64(windowHeight)-w.Y
+
+		switch w.Type {
+		case config.WidgetThermal:
+			g := thermal.New(program, stats, x, y, w.Width, w.Height)
+			g.ShowCpuCores = !w.HideCpuCores
+			g.ShowFrequency = !w.HideFrequency
+			g.Range = w.Range.Duration
+			setColor(&g.Background, w.Background)
+			setColor(&g.Foreground, w.Foreground)
+			built = append(built, &widget{texture: g.Texture, render: g.Render})
+
+		case config.WidgetGraph:
+			g := graph.New(program, stats, x, y, w.Width, w.Height, w.Metrics...)
+			g.Stacked = w.Stacked
+			g.MaxMetric = w.MaxMetric
+			g.Range = w.Range.Duration
+			if w.RowHeight > 0 {
+				g.RowHeight = w.RowHeight
+			}
+			if format := valueFormat(w.Format); format != nil {
+				g.Format = format
+			}
+			setColor(&g.Background, w.Background)
+			setColor(&g.Foreground, w.Foreground)
+			built = append(built, &widget{texture: g.Texture, render: g.Render})
+
+		case config.WidgetNetwork:
+			n := network.New(program, stats, x, y, w.Width, w.Height)
+			n.Names = cfg.Network.Names
+			setColor(&n.Background, w.Background)
+			setColor(&n.Foreground, w.Foreground)
+			built = append(built, &widget{texture: n.Texture, render: n.Render})
+
+		case config.WidgetTop:
+			count := w.Count
+			if count == 0 {
+				count = 10
+			}
+			t := top.New(program, x, y, w.Width, w.Height, count)
+			setColor(&t.Background, w.Background)
+			setColor(&t.Foreground, w.Foreground)
+			built = append(built, &widget{texture: t.Texture, render: t.Render, redraw: t.Redraw, run: t.Run, stop: t.Stop})
+
+		default:
+			for _, w := range built {
+				w.texture.Delete()
+			}
+			return nil, fmt.Errorf("widget %d: unknown type %q", i+1, w.Type)
+		}
+	}
+	return built, nil
+}
+
+func valueFormat(format string) func(float64) string {
+	switch format {
+	case "rate":
+		return func(value float64) string { return fmt.Sprintf("%.0fK/s", value/1024) }
+	case "bytes":
+		return func(value float64) string { return fmt.Sprintf("%.1fG", value/(1<<30)) }
+	case "percent":
+		return func(value float64) string { return fmt.Sprintf("%.0f%%", value) }
+	}
+	return nil
+}
+
+// setColor overrides c with value, if one is given. The config was
+// validated, so value parses.
+func setColor(c *color.RGBA, value string) {
+	if value == "" {
+		return
+	}
+	if parsed, err := config.ParseColor(value); err == nil {
+		*c = parsed
+	}
+}
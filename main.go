@@ -1,18 +1,21 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"runtime"
+	"syscall"
 	"time"
 
 	"github.com/go-gl/gl/v3.3-core/gl"
 	"github.com/go-gl/glfw/v3.2/glfw"
 
+	"github.com/lian/gonky/config"
 	"github.com/lian/gonky/shader"
 	"github.com/lian/gonky/widgets"
-	"github.com/lian/gonky/widgets/status"
-	"github.com/lian/gonky/widgets/thermal"
 )
 
 func init() {
@@ -60,8 +63,31 @@ var WindowHeight int = 600
 var program *shader.Program
 
 func main() {
+	opts, command, err := parseArgs(os.Args[1:], os.Stderr)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+	widgets.Verbose = opts.verbose
+
+	if err := commands[command](opts); err != nil {
+		fmt.Fprintln(os.Stderr, "gonky:", err)
+		os.Exit(1)
+	}
+}
+
+// run opens the window and draws the widgets until it is closed.
+func run(opts options) error {
+	configPath := opts.config
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+
 	if err := glfw.Init(); err != nil {
-		log.Fatalln("failed to initialize glfw:", err)
+		return fmt.Errorf("failed to initialize glfw: %v", err)
 	}
 	defer glfw.Terminate()
 
@@ -72,14 +98,25 @@ func main() {
 	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
 	//glfw.WindowHint(glfw.Samples, 4)
 
-	screenInfo := glfw.GetPrimaryMonitor().GetVideoMode()
-	WindowWidth := screenInfo.Width
-	WindowHeight := screenInfo.Height
+	monitor, err := selectMonitor(opts.monitor)
+	if err != nil {
+		return err
+	}
+	screenInfo := monitor.GetVideoMode()
+	WindowWidth = screenInfo.Width
+	WindowHeight = screenInfo.Height
+	x, y := monitor.GetPos()
+	if opts.windowed.set {
+		WindowWidth, WindowHeight = opts.windowed.Width, opts.windowed.Height
+		x += opts.windowed.X
+		y += opts.windowed.Y
+	}
 
-	window, err := glfw.CreateWindow(WindowWidth, WindowHeight, "derp", nil, nil)
+	window, err := glfw.CreateWindow(WindowWidth, WindowHeight, "gonky", nil, nil)
 	if err != nil {
 		panic(err)
 	}
+	window.SetPos(x, y)
 	window.MakeContextCurrent()
 	//window.SetInputMode(glfw.CursorMode, glfw.CursorDisabled)
 	//window.SetInputMode(glfw.CursorMode, glfw.CursorHidden)
@@ -95,8 +132,10 @@ func main() {
 		panic(err)
 	}
 
-	version := gl.GoStr(gl.GetString(gl.VERSION))
-	fmt.Println("OpenGL version", version)
+	if opts.verbose {
+		version := gl.GoStr(gl.GetString(gl.VERSION))
+		log.Println("OpenGL version", version)
+	}
 
 	program, err = shader.DefaultShader()
 	if err != nil {
@@ -115,13 +154,50 @@ func main() {
 		foo.Render()
 	*/
 
+	widgets.SeriesCapacity = cfg.SeriesCapacity
+
 	stats := widgets.NewStats()
+	applyCollectors(cfg, stats)
+	if history, err := widgets.OpenHistory(widgets.DefaultHistoryPath()); err != nil {
+		log.Println("history:", err)
+	} else {
+		stats.SetHistory(history)
+		defer stats.Close()
+	}
 	go stats.Run()
 
-	status := status.New(WindowWidth, WindowHeight, program, stats)
-	go status.Run()
+	screen, err := newScreen(cfg, stats, WindowWidth, WindowHeight)
+	if err != nil {
+		return err
+	}
+	redraws := make(chan *widget)
+	screen.start(redraws)
 
-	graphs := thermal.New(program, stats)
+	// reload the config when it changes or on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	changes, err := config.Watch(configPath)
+	if err != nil {
+		log.Println("config: not watching for changes:", err)
+	}
+	reload := func() {
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			log.Println("config: keeping the previous configuration:", err)
+			return
+		}
+		next, err := newScreen(cfg, stats, WindowWidth, WindowHeight)
+		if err != nil {
+			log.Println("config: keeping the previous configuration:", err)
+			return
+		}
+		applyCollectors(cfg, stats)
+		screen.close()
+		screen = next
+		screen.start(redraws)
+		screen.render()
+		log.Println("config: reloaded", configPath)
+	}
 
 	// Configure global settings
 	gl.Enable(gl.DEPTH_TEST)
@@ -140,10 +216,21 @@ func main() {
 			glfw.PollEvents()
 			continue
 		case <-stats.Updated:
-			status.Render()
-			graphs.Render()
-		case <-status.Redraw:
-			status.Render()
+			screen.render()
+		case <-screen.status.Redraw:
+			screen.status.Render()
+		case w := <-redraws:
+			if !w.closed {
+				w.render()
+			}
+		case <-hup:
+			reload()
+		case _, ok := <-changes:
+			if !ok {
+				changes = nil
+				continue
+			}
+			reload()
 		case <-maxRenderDelayTimer.C:
 			//fmt.Println("max delay tick")
 		case <-redrawChan:
@@ -155,10 +242,10 @@ func main() {
 
 		program.Use()
 		//foo.Texture.Draw()
-		status.Texture.Draw()
-		graphs.Texture.Draw()
+		screen.draw()
 
 		window.SwapBuffers()
 		glfw.PollEvents()
 	}
+	return nil
 }
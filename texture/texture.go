@@ -74,6 +74,20 @@ func (t *Texture) Clear() {
 	}
 }
 
+// Delete frees the texture and its vertex buffers. The texture can not be
+// drawn afterwards.
+func (t *Texture) Delete() {
+	t.Clear()
+	if t.vbo != 0 {
+		gl.DeleteBuffers(1, &t.vbo)
+		t.vbo = 0
+	}
+	if t.vao != 0 {
+		gl.DeleteVertexArrays(1, &t.vao)
+		t.vao = 0
+	}
+}
+
 func (t *Texture) Write(data *[]uint8) {
 	buf := gl.Ptr(*data)
 
@@ -0,0 +1,422 @@
+package widgets
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterCollector("battery", func() Collector { return &BatteryCollector{} })
+}
+
+// BatteryStatus is the state of a battery in SI units, whether the
+// driver reports energy (µWh, µW) or charge (µAh, µA).
+type BatteryStatus struct {
+	BatteryID string
+	Status    string
+	// Capacity and CapacityFull are the stored and the full energy in Wh.
+	Capacity     float64
+	CapacityFull float64
+	Percent      float64
+	// Watts and Amps are the rate of charge or discharge, Volts the
+	// current voltage.
+	Watts     float64
+	Amps      float64
+	Volts     float64
+	Remaining string
+}
+
+const batteryPath = "/sys/class/power_supply"
+
+// Power supply types as reported in /sys/class/power_supply/*/type. USB
+// covers the USB_C, USB_PD and other USB variants.
+const (
+	SupplyBattery = "Battery"
+	SupplyMains   = "Mains"
+	SupplyUSB     = "USB"
+)
+
+// PowerStatus is the state of all power supplies. Battery combines every
+// system battery, like the internal and external ones of a ThinkPad, and
+// is nil on machines without one.
+type PowerStatus struct {
+	Batteries []BatteryStatus
+	Battery   *BatteryStatus
+	// OnAC is set when a mains or USB adapter is online, or when there is
+	// no battery at all.
+	OnAC bool
+}
+
+// SupplyType returns the type of a power supply, with every USB variant
+// reported as SupplyUSB.
+func SupplyType(name string) (string, error) {
+	kind, err := ReadString(fmt.Sprintf("%s/%s/type", batteryPath, name))
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(kind, "USB") {
+		return SupplyUSB, nil
+	}
+	return kind, nil
+}
+
+// ReadPower reads every battery and adapter.
+func ReadPower() (*PowerStatus, error) {
+	dirs, err := ReadDir(batteryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	power := &PowerStatus{}
+	adapters := 0
+	for _, dir := range dirs {
+		name := dir.Name()
+		kind, err := SupplyType(name)
+		if err != nil {
+			continue
+		}
+		switch kind {
+		case SupplyBattery:
+			if !isSystemBattery(name) {
+				continue
+			}
+			battery, err := ReadBattery(name)
+			if err != nil {
+				return nil, err
+			}
+			power.Batteries = append(power.Batteries, *battery)
+		case SupplyMains, SupplyUSB:
+			adapters++
+			if online, _ := ReadString(fmt.Sprintf("%s/%s/online", batteryPath, name)); online == "1" {
+				power.OnAC = true
+			}
+		}
+	}
+
+	if len(power.Batteries) == 0 {
+		power.OnAC = true
+	} else {
+		power.Battery = CombineBatteries(power.Batteries)
+		// without an adapter entry the battery status is all there is
+		if adapters == 0 {
+			power.OnAC = power.Battery.Status != "Discharging"
+		}
+	}
+	return power, nil
+}
+
+// ReadBatteries reads every system battery, leaving out adapters and the
+// batteries of peripherals like wireless mice.
+func ReadBatteries() ([]BatteryStatus, error) {
+	power, err := ReadPower()
+	if err != nil {
+		return nil, err
+	}
+	return power.Batteries, nil
+}
+
+// isSystemBattery reports whether a battery powers the machine itself.
+// Peripherals report a Device scope.
+func isSystemBattery(name string) bool {
+	scope, err := ReadString(fmt.Sprintf("%s/%s/scope", batteryPath, name))
+	return err != nil || scope != "Device"
+}
+
+// CombineBatteries adds up the charge and draw of several batteries, so
+// the charge and time remaining cover all of them.
+func CombineBatteries(batteries []BatteryStatus) *BatteryStatus {
+	if len(batteries) == 1 {
+		b := batteries[0]
+		return &b
+	}
+
+	combined := &BatteryStatus{}
+	var ids []string
+	charging, discharging, full := false, false, true
+	for _, b := range batteries {
+		ids = append(ids, b.BatteryID)
+		combined.Capacity += b.Capacity
+		combined.CapacityFull += b.CapacityFull
+		combined.Watts += b.Watts
+		combined.Amps += b.Amps
+		switch b.Status {
+		case "Charging":
+			charging = true
+		case "Discharging":
+			discharging = true
+		}
+		if b.Status != "Full" {
+			full = false
+		}
+	}
+	combined.BatteryID = strings.Join(ids, "+")
+
+	switch {
+	case discharging:
+		combined.Status = "Discharging"
+	case charging:
+		combined.Status = "Charging"
+	case full:
+		combined.Status = "Full"
+	default:
+		combined.Status = "Idle"
+	}
+
+	if combined.Amps > 0 {
+		combined.Volts = combined.Watts / combined.Amps
+	}
+	if combined.CapacityFull > 0 {
+		combined.Percent = (combined.Capacity * 100.0) / combined.CapacityFull
+	}
+	combined.Remaining = remaining(combined)
+	return combined
+}
+
+func ReadBattery(name string) (*BatteryStatus, error) {
+	file, err := ReadFile(fmt.Sprintf("%s/%s/uevent", batteryPath, name))
+	if err != nil {
+		return nil, err
+	}
+
+	vars := map[string]string{}
+
+	for _, line := range strings.Split(string(file), "\n") {
+		buf := strings.Split(line, "=")
+		if len(buf) == 2 {
+			vars[buf[0]] = buf[1]
+		}
+	}
+
+	battery := &BatteryStatus{
+		BatteryID: name,
+		Status:    vars["POWER_SUPPLY_STATUS"],
+	}
+
+	battery.Volts = microUnits(vars["POWER_SUPPLY_VOLTAGE_NOW"])
+	if _, ok := vars["POWER_SUPPLY_ENERGY_NOW"]; ok {
+		battery.Capacity = microUnits(vars["POWER_SUPPLY_ENERGY_NOW"])
+		battery.CapacityFull = microUnits(vars["POWER_SUPPLY_ENERGY_FULL"])
+		battery.Watts = microUnits(vars["POWER_SUPPLY_POWER_NOW"])
+		if current, ok := vars["POWER_SUPPLY_CURRENT_NOW"]; ok {
+			battery.Amps = microUnits(current)
+		} else if battery.Volts > 0 {
+			battery.Amps = battery.Watts / battery.Volts
+		}
+	} else {
+		// charge in µAh and current in µA. The charge is converted at the
+		// design voltage, the current voltage sags under load and would
+		// make the capacity drift with it.
+		volts := battery.Volts
+		if design := microUnits(vars["POWER_SUPPLY_VOLTAGE_MIN_DESIGN"]); design > 0 {
+			volts = design
+		}
+		battery.Capacity = microUnits(vars["POWER_SUPPLY_CHARGE_NOW"]) * volts
+		battery.CapacityFull = microUnits(vars["POWER_SUPPLY_CHARGE_FULL"]) * volts
+		battery.Amps = microUnits(vars["POWER_SUPPLY_CURRENT_NOW"])
+		battery.Watts = battery.Amps * battery.Volts
+	}
+
+	if battery.CapacityFull > 0 {
+		battery.Percent = (battery.Capacity * 100.0) / battery.CapacityFull
+	} else {
+		battery.Percent, _ = strconv.ParseFloat(vars["POWER_SUPPLY_CAPACITY"], 64)
+	}
+
+	// "Not charging" is reported when a charge threshold holds the
+	// battery where it is, neither it nor "Unknown" has a time remaining
+	if battery.Status == "Unknown" || battery.Status == "Not charging" {
+		battery.Status = "Idle"
+	}
+
+	battery.Remaining = remaining(battery)
+
+	return battery, nil
+}
+
+// remaining formats the time until the battery is empty, or full while
+// charging, as hh:mm.
+func remaining(battery *BatteryStatus) string {
+	if battery.Watts <= 0 {
+		return "00:00"
+	}
+
+	hours := 0.0
+	if battery.Status == "Charging" {
+		hours = (battery.CapacityFull - battery.Capacity) / battery.Watts
+	} else {
+		hours = battery.Capacity / battery.Watts
+	}
+
+	seconds := int(hours * 3600)
+	h := seconds / 3600
+	m := (seconds - (h * 3600)) / 60
+	return fmt.Sprintf("%.2d:%.2d", h, m)
+}
+
+// microUnits parses a power_supply value given in millionths. Some
+// drivers report the current or power as negative while discharging, the
+// sign is dropped.
+func microUnits(value string) float64 {
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	if v < 0 {
+		v = -v
+	}
+	return float64(v) / 1e6
+}
+
+// BatteryEstimateWindow is how far back the battery collector looks to
+// estimate the rate of charge or discharge.
+var BatteryEstimateWindow = 10 * time.Minute
+
+// Battery is the state of the BatteryCollector, the combined battery.
+type Battery struct {
+	Status *PowerStatus
+	// Level is the charge in percent and Power the charge or discharge
+	// rate in watts.
+	Level *Series[float64]
+	Power *Series[float64]
+	// Estimate is the time until the battery is empty, or full while
+	// charging. It is zero until there is enough history to tell.
+	Estimate time.Duration
+}
+
+func newBattery() *Battery {
+	return &Battery{
+		Level: NewSeries[float64]("battery.level", SeriesCapacity),
+		Power: NewSeries[float64]("battery.power", SeriesCapacity),
+	}
+}
+
+func (b *Battery) Metrics() []Metric {
+	return []Metric{b.Level, b.Power}
+}
+
+func (b *Battery) Clone() State {
+	c := *b
+	if b.Status != nil {
+		status := *b.Status
+		status.Batteries = append([]BatteryStatus(nil), b.Status.Batteries...)
+		if b.Status.Battery != nil {
+			combined := *b.Status.Battery
+			status.Battery = &combined
+		}
+		c.Status = &status
+	}
+	c.Level = b.Level.Clone()
+	c.Power = b.Power.Clone()
+	return &c
+}
+
+// Battery returns the state of the battery collector, or nil on machines
+// without a battery.
+func (s *Sample) Battery() *Battery {
+	return stateOf(s, "battery", func() *Battery { return nil })
+}
+
+type energyReading struct {
+	at time.Time
+	wh float64
+}
+
+// BatteryCollector reads the power supplies and estimates the time
+// remaining. The estimate comes from a least squares fit of the stored
+// energy over the last BatteryEstimateWindow, which follows the actual
+// drain better than the momentary power draw. Until the window holds a
+// minute of readings the exponentially smoothed draw is used instead.
+type BatteryCollector struct {
+	status   string
+	readings []energyReading
+	smoothed float64
+}
+
+func (c *BatteryCollector) Name() string            { return "battery" }
+func (c *BatteryCollector) Interval() time.Duration { return time.Second * 10 }
+
+func (c *BatteryCollector) Collect(s *Sample) error {
+	power, err := ReadPower()
+	if err != nil {
+		return err
+	}
+	if power.Battery == nil {
+		s.SetState("battery", nil)
+		return nil
+	}
+	state := ensureState(s, "battery", newBattery)
+	b := power.Battery
+	now := time.Now()
+
+	// the rate only makes sense within one charge or discharge cycle
+	if b.Status != c.status {
+		c.status = b.Status
+		c.readings = c.readings[:0]
+		c.smoothed = 0
+	}
+	c.readings = append(c.readings, energyReading{at: now, wh: b.Capacity})
+	for len(c.readings) > 0 && now.Sub(c.readings[0].at) > BatteryEstimateWindow {
+		c.readings = c.readings[1:]
+	}
+	if c.smoothed == 0 {
+		c.smoothed = b.Watts
+	} else {
+		c.smoothed += 0.3 * (b.Watts - c.smoothed)
+	}
+
+	state.Status = power
+	state.Level.PushAt(now, b.Percent)
+	state.Power.PushAt(now, b.Watts)
+	state.Estimate = c.estimate(b)
+	return nil
+}
+
+func (c *BatteryCollector) estimate(b *BatteryStatus) time.Duration {
+	var left float64
+	switch b.Status {
+	case "Discharging":
+		left = b.Capacity
+	case "Charging":
+		left = b.CapacityFull - b.Capacity
+	default:
+		return 0
+	}
+
+	// Wh per hour, positive in the direction of the current status
+	rate := c.smoothed
+	if len(c.readings) > 2 && c.readings[len(c.readings)-1].at.Sub(c.readings[0].at) >= time.Minute {
+		slope := energySlope(c.readings)
+		if b.Status == "Discharging" {
+			slope = -slope
+		}
+		if slope > 0 {
+			rate = slope
+		}
+	}
+	if rate <= 0 || left <= 0 {
+		return 0
+	}
+	return time.Duration(left / rate * float64(time.Hour))
+}
+
+// energySlope fits a line through the readings and returns its slope in
+// Wh per hour.
+func energySlope(readings []energyReading) float64 {
+	start := readings[0].at
+	var sumX, sumY, sumXY, sumXX float64
+	for _, r := range readings {
+		x := r.at.Sub(start).Hours()
+		sumX += x
+		sumY += r.wh
+		sumXY += x * r.wh
+		sumXX += x * x
+	}
+	n := float64(len(readings))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denominator
+}
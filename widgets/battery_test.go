@@ -0,0 +1,148 @@
+package widgets
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func batteryIDs(batteries []BatteryStatus) []string {
+	var ids []string
+	for _, b := range batteries {
+		ids = append(ids, b.BatteryID)
+	}
+	return ids
+}
+
+func TestReadPower(t *testing.T) {
+	useRoot(t, fixtureRoot)
+	power, err := ReadPower()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the mouse battery is not a system battery
+	if ids := batteryIDs(power.Batteries); len(ids) != 2 || ids[0] != "BAT0" || ids[1] != "BAT1" {
+		t.Fatalf("batteries %v, want BAT0 and BAT1", ids)
+	}
+	if power.Battery == nil || power.Battery.BatteryID != "BAT0+BAT1" || power.Battery.Status != "Discharging" {
+		t.Errorf("combined battery %+v", power.Battery)
+	}
+	if power.OnAC {
+		t.Error("on AC with both adapters offline")
+	}
+}
+
+func TestReadBatteryUnits(t *testing.T) {
+	useRoot(t, fixtureRoot)
+
+	// energy based, in µWh and µW
+	bat0, err := ReadBattery("BAT0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(bat0.Capacity, 30) || !near(bat0.CapacityFull, 50) || !near(bat0.Percent, 60) {
+		t.Errorf("BAT0 holds %v of %v Wh, %v%%", bat0.Capacity, bat0.CapacityFull, bat0.Percent)
+	}
+	if !near(bat0.Watts, 10) || !near(bat0.Volts, 12) || !near(bat0.Amps, 10.0/12) {
+		t.Errorf("BAT0 draws %v W at %v V, %v A", bat0.Watts, bat0.Volts, bat0.Amps)
+	}
+
+	// charge based, in µAh converted at the design voltage
+	bat1, err := ReadBattery("BAT1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(bat1.Capacity, 20) || !near(bat1.CapacityFull, 40) || !near(bat1.Volts, 11) || bat1.Watts != 0 {
+		t.Errorf("BAT1 holds %v of %v Wh at %v V, %v W", bat1.Capacity, bat1.CapacityFull, bat1.Volts, bat1.Watts)
+	}
+
+	combined := CombineBatteries([]BatteryStatus{*bat0, *bat1})
+	if !near(combined.Capacity, 50) || !near(combined.CapacityFull, 90) || !near(combined.Watts, 10) || !near(combined.Percent, 50.0*100/90) {
+		t.Errorf("combined %+v", combined)
+	}
+}
+
+func TestReadPowerAdapters(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		onAC  bool
+	}{
+		{"no supplies", map[string]string{"/sys/class/power_supply/.keep": ""}, true},
+		{"usb-c adapter", map[string]string{
+			"/sys/class/power_supply/BAT0/type":                "Battery\n",
+			"/sys/class/power_supply/BAT0/uevent":              "POWER_SUPPLY_STATUS=Charging\n",
+			"/sys/class/power_supply/ucsi-source-psy-1/type":   "USB_PD\n",
+			"/sys/class/power_supply/ucsi-source-psy-1/online": "1\n",
+		}, true},
+		{"no adapter entry", map[string]string{
+			"/sys/class/power_supply/BAT0/type":   "Battery\n",
+			"/sys/class/power_supply/BAT0/uevent": "POWER_SUPPLY_STATUS=Discharging\n",
+		}, false},
+	}
+	for _, test := range tests {
+		useRoot(t, writeFixture(t, test.files))
+		power, err := ReadPower()
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if power.OnAC != test.onAC {
+			t.Errorf("%s: on AC %v, want %v", test.name, power.OnAC, test.onAC)
+		}
+	}
+}
+
+func TestBatteryRemaining(t *testing.T) {
+	useRoot(t, fixtureRoot)
+	power, err := ReadPower()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bat0, bat1 := power.Batteries[0], power.Batteries[1]
+	if bat0.Remaining != "03:00" {
+		t.Errorf("BAT0 has %s remaining, want 03:00", bat0.Remaining)
+	}
+	// held by a charge threshold
+	if bat1.Status != "Idle" || bat1.Remaining != "00:00" {
+		t.Errorf("BAT1 is %q with %s remaining, want Idle", bat1.Status, bat1.Remaining)
+	}
+	if power.Battery.Remaining != "05:00" {
+		t.Errorf("combined %s remaining, want 05:00", power.Battery.Remaining)
+	}
+}
+
+func TestBatteryEstimate(t *testing.T) {
+	start := time.Now()
+	c := &BatteryCollector{status: "Discharging", smoothed: 20}
+	// 12 Wh per hour over ten minutes
+	for i := 0; i <= 10; i++ {
+		c.readings = append(c.readings, energyReading{at: start.Add(time.Duration(i) * time.Minute), wh: 50 - float64(i)*0.2})
+	}
+	if slope := energySlope(c.readings); !near(slope, -12) {
+		t.Errorf("slope %v, want -12", slope)
+	}
+
+	b := &BatteryStatus{Status: "Discharging", Capacity: 48, CapacityFull: 60}
+	if got := c.estimate(b); (got - 4*time.Hour).Abs() > time.Second {
+		t.Errorf("discharging estimate %v, want 4h", got)
+	}
+	for _, status := range []string{"Idle", "Full"} {
+		b.Status = status
+		if got := c.estimate(b); got != 0 {
+			t.Errorf("%s estimate %v, want none", status, got)
+		}
+	}
+
+	// too short for a fit, the smoothed draw is used
+	c.readings = c.readings[:2]
+	b.Status = "Discharging"
+	if got := c.estimate(b); got != 48*time.Hour/20 {
+		t.Errorf("smoothed estimate %v, want %v", got, 48*time.Hour/20)
+	}
+}
@@ -0,0 +1,91 @@
+package widgets
+
+import (
+	"fmt"
+	"path"
+	"time"
+)
+
+// Collector is a metric source that is polled by Stats.Run on its own
+// interval. It keeps its readings in a State of the Sample, stored under
+// its name.
+type Collector interface {
+	Name() string
+	Interval() time.Duration
+	Collect(s *Sample) error
+}
+
+// IntervalSetter is implemented by collectors that need to know the
+// interval they are polled at, like the ones that turn counters into
+// rates. Stats.Run calls SetInterval before the first collection and
+// whenever the interval changes.
+type IntervalSetter interface {
+	SetInterval(interval time.Duration)
+}
+
+// MetricNames are the names of the series the built-in collectors keep,
+// with "*" for the parts that depend on the machine like the device in
+// "disk.*.read". Collectors registered elsewhere can add theirs.
+var MetricNames = []string{
+	"cpu", "cpu.*",
+	"cpufreq", "cpufreq.*", "throttle",
+	"thermal",
+	"fan",
+	"memory", "memory.total", "memory.used", "memory.buffers", "memory.cached", "memory.available",
+	"swap.used", "swap.total",
+	"load.1", "load.5", "load.15", "tasks.running", "tasks.total", "ctxt", "intr",
+	"psi.*.*.avg10", "psi.*.*.avg60", "psi.*.*.avg300",
+	"net.*.rx", "net.*.tx", "net.*.rx_packets", "net.*.tx_packets",
+	"net.*.rx_errors", "net.*.tx_errors", "net.*.rx_drops", "net.*.tx_drops",
+	"net.*.quality", "net.*.signal",
+	"disk.*.read", "disk.*.write", "fs.*.used",
+	"power.*",
+	"battery.level", "battery.power",
+}
+
+// IsMetricName reports whether name matches one of MetricNames.
+func IsMetricName(name string) bool {
+	for _, pattern := range MetricNames {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type collectorFactory struct {
+	name string
+	new  func() Collector
+}
+
+var collectorRegistry []collectorFactory
+
+// RegisterCollector makes a collector available to NewStats. It is meant
+// to be called from init functions, the same way the built-in collectors
+// register themselves.
+func RegisterCollector(name string, factory func() Collector) {
+	for _, f := range collectorRegistry {
+		if f.name == name {
+			panic(fmt.Sprintf("widgets: collector %q registered twice", name))
+		}
+	}
+	collectorRegistry = append(collectorRegistry, collectorFactory{name: name, new: factory})
+}
+
+// RegisteredCollectors returns the names of all registered collectors in
+// registration order.
+func RegisteredCollectors() []string {
+	names := make([]string, 0, len(collectorRegistry))
+	for _, f := range collectorRegistry {
+		names = append(names, f.name)
+	}
+	return names
+}
+
+func newCollectors() []Collector {
+	collectors := make([]Collector, 0, len(collectorRegistry))
+	for _, f := range collectorRegistry {
+		collectors = append(collectors, f.new())
+	}
+	return collectors
+}
@@ -0,0 +1,97 @@
+package widgets
+
+import (
+	"fmt"
+	"time"
+
+	psutil_cpu "github.com/shirou/gopsutil/cpu"
+)
+
+func init() {
+	RegisterCollector("cpu", func() Collector { return &CPUCollector{} })
+}
+
+// CpuUsage is the state of the CPUCollector, the utilisation in percent.
+type CpuUsage struct {
+	Total *Series[float64]
+	// Cores holds the utilisation of each core.
+	Cores []*Series[float64]
+}
+
+func newCpuUsage() *CpuUsage {
+	return &CpuUsage{Total: NewSeries[float64]("cpu", SeriesCapacity)}
+}
+
+func (u *CpuUsage) Metrics() []Metric {
+	metrics := []Metric{u.Total}
+	for _, core := range u.Cores {
+		metrics = append(metrics, core)
+	}
+	return metrics
+}
+
+func (u *CpuUsage) Clone() State {
+	c := &CpuUsage{Total: u.Total.Clone(), Cores: make([]*Series[float64], len(u.Cores))}
+	for i, core := range u.Cores {
+		c.Cores[i] = core.Clone()
+	}
+	return c
+}
+
+// Cpu returns the state of the cpu collector.
+func (s *Sample) Cpu() *CpuUsage { return stateOf(s, "cpu", newCpuUsage) }
+
+// CPUCollector reports the total and per-core CPU utilisation since the
+// previous call.
+//
+// gopsutil takes the first cpu times from its package init, before Root
+// is set, so the first call only takes new ones below Root.
+type CPUCollector struct {
+	primed bool
+}
+
+func (c *CPUCollector) Name() string            { return "cpu" }
+func (c *CPUCollector) Interval() time.Duration { return time.Second * 5 }
+
+func (c *CPUCollector) Collect(s *Sample) error {
+	//info, _ := psutil_cpu.Info(); spew.Dump(info)
+	if !c.primed {
+		// the baseline from the package init may have a different number
+		// of cpus than Root, which gopsutil reports as an error while
+		// still taking the new times
+		psutil_cpu.Percent(0, false)
+		psutil_cpu.Percent(0, true)
+		c.primed = true
+		return nil
+	}
+
+	percent, err := psutil_cpu.Percent(0, false)
+	if err != nil {
+		return err
+	}
+	if len(percent) != 1 {
+		return fmt.Errorf("unexpected cpu percent values: %v", percent)
+	}
+
+	u := ensureState(s, "cpu", newCpuUsage)
+	u.Total.Push(percent[0])
+
+	cores, err := psutil_cpu.Percent(0, true)
+	if err != nil {
+		return err
+	}
+
+	// cpus can go on- and offline, start the per core history over when
+	// the number of cores changes
+	if len(cores) != len(u.Cores) {
+		u.Cores = make([]*Series[float64], len(cores))
+		for i := range u.Cores {
+			u.Cores[i] = NewSeries[float64](fmt.Sprintf("cpu.%d", i), u.Total.Cap())
+		}
+	}
+
+	for i, value := range cores {
+		u.Cores[i].Push(value)
+	}
+	return nil
+}
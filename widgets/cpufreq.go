@@ -0,0 +1,148 @@
+package widgets
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterCollector("cpufreq", func() Collector { return &CpuFreqCollector{} })
+}
+
+// CpuFreq holds the frequency scaling state of the cpus.
+type CpuFreq struct {
+	// Average is the mean frequency over all cores and Cores the
+	// frequency of each core, in MHz. Max is the highest frequency any
+	// core supports.
+	Average *Series[float64]
+	Cores   []*Series[float64]
+	Max     float64
+
+	// Governor and EnergyPreference are those of cpu0, they are usually
+	// the same for all cores.
+	Governor         string
+	EnergyPreference string
+
+	// Throttles is the number of thermal throttle events of all cores and
+	// packages since the previous sample. CoreThrottles and
+	// PackageThrottles are the counters since boot.
+	Throttles        *Series[float64]
+	CoreThrottles    uint64
+	PackageThrottles uint64
+}
+
+func newCpuFreq() *CpuFreq {
+	return &CpuFreq{
+		Average:   NewSeries[float64]("cpufreq", SeriesCapacity),
+		Throttles: NewSeries[float64]("throttle", SeriesCapacity),
+	}
+}
+
+func (f *CpuFreq) Metrics() []Metric {
+	metrics := []Metric{f.Average, f.Throttles}
+	for _, core := range f.Cores {
+		metrics = append(metrics, core)
+	}
+	return metrics
+}
+
+func (f *CpuFreq) Clone() State {
+	c := *f
+	c.Average = f.Average.Clone()
+	c.Throttles = f.Throttles.Clone()
+	c.Cores = make([]*Series[float64], len(f.Cores))
+	for i, core := range f.Cores {
+		c.Cores[i] = core.Clone()
+	}
+	return &c
+}
+
+// CpuFreq returns the state of the cpufreq collector.
+func (s *Sample) CpuFreq() *CpuFreq { return stateOf(s, "cpufreq", newCpuFreq) }
+
+// CpuFreqCollector reads cpufreq and thermal_throttle of every cpu below
+// /sys/devices/system/cpu.
+type CpuFreqCollector struct {
+	lastThrottles uint64
+	seen          bool
+}
+
+func (c *CpuFreqCollector) Name() string            { return "cpufreq" }
+func (c *CpuFreqCollector) Interval() time.Duration { return time.Second * 5 }
+
+func (c *CpuFreqCollector) Collect(s *Sample) error {
+	cpus, err := Glob("/sys/devices/system/cpu/cpu[0-9]*")
+	if err != nil {
+		return err
+	}
+	sort.Slice(cpus, func(i, j int) bool { return cpuIndex(cpus[i]) < cpuIndex(cpus[j]) })
+
+	f := ensureState(s, "cpufreq", newCpuFreq)
+	var freqs []float64
+	var coreThrottles, packageThrottles uint64
+	for _, cpu := range cpus {
+		if khz, err := readUint(filepath.Join(cpu, "cpufreq/scaling_cur_freq")); err == nil {
+			freqs = append(freqs, float64(khz)/1000)
+		}
+		if khz, err := readUint(filepath.Join(cpu, "cpufreq/cpuinfo_max_freq")); err == nil && float64(khz)/1000 > f.Max {
+			f.Max = float64(khz) / 1000
+		}
+
+		if count, err := readUint(filepath.Join(cpu, "thermal_throttle/core_throttle_count")); err == nil {
+			coreThrottles += count
+		}
+		// every core of a package reports the same package counter
+		if count, err := readUint(filepath.Join(cpu, "thermal_throttle/package_throttle_count")); err == nil && count > packageThrottles {
+			packageThrottles = count
+		}
+	}
+
+	if len(freqs) == 0 {
+		return fmt.Errorf("no cpufreq information found")
+	}
+
+	if len(freqs) != len(f.Cores) {
+		f.Cores = make([]*Series[float64], len(freqs))
+		for i := range f.Cores {
+			f.Cores[i] = NewSeries[float64](fmt.Sprintf("cpufreq.%d", i), f.Average.Cap())
+		}
+	}
+	sum := 0.0
+	for i, freq := range freqs {
+		f.Cores[i].Push(freq)
+		sum += freq
+	}
+	f.Average.Push(sum / float64(len(freqs)))
+
+	f.Governor, _ = ReadString("/sys/devices/system/cpu/cpu0/cpufreq/scaling_governor")
+	f.EnergyPreference, _ = ReadString("/sys/devices/system/cpu/cpu0/cpufreq/energy_performance_preference")
+
+	f.CoreThrottles = coreThrottles
+	f.PackageThrottles = packageThrottles
+	throttles := coreThrottles + packageThrottles
+	if c.seen && throttles >= c.lastThrottles {
+		f.Throttles.Push(float64(throttles - c.lastThrottles))
+	} else {
+		f.Throttles.Push(0)
+	}
+	c.lastThrottles = throttles
+	c.seen = true
+	return nil
+}
+
+func cpuIndex(path string) int {
+	i, _ := strconv.Atoi(strings.TrimPrefix(filepath.Base(path), "cpu"))
+	return i
+}
+
+func readUint(path string) (uint64, error) {
+	buf, err := ReadString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(buf, 10, 64)
+}
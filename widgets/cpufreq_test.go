@@ -0,0 +1,73 @@
+package widgets
+
+import "testing"
+
+func cpufreqFixture(t *testing.T, coreThrottles string) string {
+	const cpu = "/sys/devices/system/cpu/"
+	return writeFixture(t, map[string]string{
+		cpu + "cpu0/cpufreq/scaling_cur_freq":                "800000\n",
+		cpu + "cpu0/cpufreq/cpuinfo_max_freq":                "4200000\n",
+		cpu + "cpu0/cpufreq/scaling_governor":                "powersave\n",
+		cpu + "cpu0/cpufreq/energy_performance_preference":   "balance_power\n",
+		cpu + "cpu0/thermal_throttle/core_throttle_count":    coreThrottles,
+		cpu + "cpu0/thermal_throttle/package_throttle_count": "3\n",
+		cpu + "cpu1/cpufreq/scaling_cur_freq":                "1200000\n",
+		cpu + "cpu1/cpufreq/cpuinfo_max_freq":                "4600000\n",
+		cpu + "cpu1/thermal_throttle/core_throttle_count":    "1\n",
+		cpu + "cpu1/thermal_throttle/package_throttle_count": "3\n",
+		// offline, the kernel removes its cpufreq directory
+		cpu + "cpu2/online": "0\n",
+		// without a cpufreq driver
+		cpu + "cpu3/topology/core_id": "3\n",
+		// sorted by number, not name
+		cpu + "cpu10/cpufreq/scaling_cur_freq": "2200000\n",
+		// the policies are not cpus
+		cpu + "cpufreq/policy0/scaling_cur_freq": "9999999\n",
+	})
+}
+
+func TestCpuFreqCollector(t *testing.T) {
+	useRoot(t, cpufreqFixture(t, "2\n"))
+	c := &CpuFreqCollector{}
+	s := &Sample{}
+	if err := c.Collect(s); err != nil {
+		t.Fatal(err)
+	}
+	f := s.CpuFreq()
+
+	var cores []float64
+	for _, core := range f.Cores {
+		cores = append(cores, core.Last())
+	}
+	if len(cores) != 3 || cores[0] != 800 || cores[1] != 1200 || cores[2] != 2200 {
+		t.Fatalf("cores at %v MHz, want 800, 1200 and 2200", cores)
+	}
+	if f.Average.Last() != 1400 || f.Max != 4600 {
+		t.Errorf("average %v, max %v MHz, want 1400 and 4600", f.Average.Last(), f.Max)
+	}
+	if f.Governor != "powersave" || f.EnergyPreference != "balance_power" {
+		t.Errorf("governor %q, preference %q", f.Governor, f.EnergyPreference)
+	}
+	if f.CoreThrottles != 3 || f.PackageThrottles != 3 || f.Throttles.Last() != 0 {
+		t.Errorf("throttles %d core, %d package, %v new", f.CoreThrottles, f.PackageThrottles, f.Throttles.Last())
+	}
+
+	useRoot(t, cpufreqFixture(t, "6\n"))
+	if err := c.Collect(s); err != nil {
+		t.Fatal(err)
+	}
+	if f.Throttles.Last() != 4 {
+		t.Errorf("%v new throttles, want 4", f.Throttles.Last())
+	}
+}
+
+func TestCpuFreqCollectorNoCpufreq(t *testing.T) {
+	useRoot(t, writeFixture(t, map[string]string{"/sys/devices/system/cpu/cpu0/online": "1\n"}))
+	s := &Sample{}
+	if err := (&CpuFreqCollector{}).Collect(s); err == nil {
+		t.Error("no error without any cpufreq")
+	}
+	if f := s.CpuFreq(); f.Average.Len() != 0 {
+		t.Error("average pushed without any cpufreq")
+	}
+}
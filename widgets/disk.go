@@ -0,0 +1,237 @@
+package widgets
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+func init() {
+	RegisterCollector("disk", func() Collector {
+		return &DiskCollector{mounts: []string{"/"}, last: map[string]*diskCounters{}}
+	})
+}
+
+// diskstats always counts in 512 byte sectors, whatever the device uses.
+const diskSectorSize = 512
+
+// Disk is the throughput of a block device in bytes per second.
+type Disk struct {
+	Name  string
+	Read  *Series[float64]
+	Write *Series[float64]
+}
+
+// Filesystem is the space usage of a mount point.
+type Filesystem struct {
+	Mount string
+	Total uint64
+	Free  uint64
+	// Used is the used space in percent, named "fs.<name>.used" after
+	// FilesystemName.
+	Used *Series[float64]
+}
+
+// FilesystemName is the name of a mount point in series names. Slashes
+// and dots would get in the way of metric patterns, so "/" is "root",
+// "/home" is "home" and "/mnt/data" is "mnt_data".
+func FilesystemName(mount string) string {
+	name := strings.Trim(mount, "/")
+	if name == "" {
+		return "root"
+	}
+	return strings.NewReplacer("/", "_", ".", "_").Replace(name)
+}
+
+// DiskUsage is the state of the DiskCollector. Disks is keyed by block
+// device name, Filesystems by mount point.
+type DiskUsage struct {
+	Disks       map[string]*Disk
+	Filesystems map[string]*Filesystem
+}
+
+func newDiskUsage() *DiskUsage {
+	return &DiskUsage{Disks: map[string]*Disk{}, Filesystems: map[string]*Filesystem{}}
+}
+
+func (u *DiskUsage) Metrics() []Metric {
+	var metrics []Metric
+	for _, disk := range u.Disks {
+		metrics = append(metrics, disk.Read, disk.Write)
+	}
+	for _, fs := range u.Filesystems {
+		metrics = append(metrics, fs.Used)
+	}
+	return metrics
+}
+
+func (u *DiskUsage) Clone() State {
+	c := &DiskUsage{
+		Disks:       make(map[string]*Disk, len(u.Disks)),
+		Filesystems: make(map[string]*Filesystem, len(u.Filesystems)),
+	}
+	for name, disk := range u.Disks {
+		c.Disks[name] = &Disk{Name: disk.Name, Read: disk.Read.Clone(), Write: disk.Write.Clone()}
+	}
+	for mount, fs := range u.Filesystems {
+		clone := *fs
+		clone.Used = fs.Used.Clone()
+		c.Filesystems[mount] = &clone
+	}
+	return c
+}
+
+// Disk returns the state of the disk collector.
+func (s *Sample) Disk() *DiskUsage { return stateOf(s, "disk", newDiskUsage) }
+
+type diskCounters struct {
+	read    Counter
+	written Counter
+}
+
+// DiskCollector reads the block device counters from /proc/diskstats and
+// the usage of its mount points, only "/" until SetMounts, with statfs.
+type DiskCollector struct {
+	mu     sync.Mutex
+	mounts []string
+
+	last map[string]*diskCounters
+	gap  time.Duration
+}
+
+// SetMounts changes the mount points whose usage is collected. It can be
+// called while the collector is running.
+func (c *DiskCollector) SetMounts(mounts []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mounts = mounts
+}
+
+func (c *DiskCollector) Name() string            { return "disk" }
+func (c *DiskCollector) Interval() time.Duration { return time.Second * 5 }
+
+func (c *DiskCollector) SetInterval(interval time.Duration) {
+	c.gap = rateGap(interval)
+	for _, counters := range c.last {
+		counters.read.MaxGap = c.gap
+		counters.written.MaxGap = c.gap
+	}
+}
+
+func (c *DiskCollector) Collect(s *Sample) error {
+	u := ensureState(s, "disk", newDiskUsage)
+	err := c.collectDiskstats(u)
+
+	c.mu.Lock()
+	mounts := c.mounts
+	c.mu.Unlock()
+
+	for mount := range u.Filesystems {
+		if !contains(mounts, mount) {
+			delete(u.Filesystems, mount)
+		}
+	}
+
+	for _, mount := range mounts {
+		var st syscall.Statfs_t
+		if serr := syscall.Statfs(HostPath(mount), &st); serr != nil {
+			err = fmt.Errorf("statfs %s: %v", mount, serr)
+			continue
+		}
+
+		fs, ok := u.Filesystems[mount]
+		if !ok {
+			fs = &Filesystem{Mount: mount, Used: NewSeries[float64]("fs."+FilesystemName(mount)+".used", SeriesCapacity)}
+			u.Filesystems[mount] = fs
+		}
+		fs.Total = st.Blocks * uint64(st.Bsize)
+		fs.Free = st.Bavail * uint64(st.Bsize)
+
+		// like df, used is relative to the space available to users
+		used := (st.Blocks - st.Bfree) * uint64(st.Bsize)
+		if used+fs.Free > 0 {
+			fs.Used.Push(float64(used) * 100 / float64(used+fs.Free))
+		} else {
+			fs.Used.Push(0)
+		}
+	}
+
+	return err
+}
+
+func (c *DiskCollector) collectDiskstats(u *DiskUsage) error {
+	buf, err := ReadFile("/proc/diskstats")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		name := fields[2]
+		if !isWholeDisk(name) {
+			continue
+		}
+		sectorsRead, err1 := strconv.ParseUint(fields[5], 10, 64)
+		sectorsWritten, err2 := strconv.ParseUint(fields[9], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		seen[name] = true
+
+		counters, ok := c.last[name]
+		if !ok {
+			counters = &diskCounters{read: Counter{MaxGap: c.gap}, written: Counter{MaxGap: c.gap}}
+			c.last[name] = counters
+		}
+
+		disk, ok := u.Disks[name]
+		if !ok {
+			disk = &Disk{
+				Name:  name,
+				Read:  NewSeries[float64]("disk."+name+".read", SeriesCapacity),
+				Write: NewSeries[float64]("disk."+name+".write", SeriesCapacity),
+			}
+			u.Disks[name] = disk
+		}
+
+		counters.read.Push(disk.Read, now, sectorsRead*diskSectorSize)
+		counters.written.Push(disk.Write, now, sectorsWritten*diskSectorSize)
+	}
+
+	for name := range u.Disks {
+		if !seen[name] {
+			delete(u.Disks, name)
+			delete(c.last, name)
+		}
+	}
+	return scanner.Err()
+}
+
+// isWholeDisk reports whether a diskstats entry is a disk rather than a
+// partition or a loop or ram device.
+func isWholeDisk(name string) bool {
+	if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+		return false
+	}
+	return Exists("/sys/block/" + name)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package widgets
+
+import "testing"
+
+func TestDiskCollector(t *testing.T) {
+	useRoot(t, fixtureRoot)
+	c := &DiskCollector{mounts: []string{"/"}, last: map[string]*diskCounters{}}
+	s := &Sample{}
+	for i := 0; i < 2; i++ {
+		if err := c.Collect(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// partitions and loop devices are left out
+	if len(s.Disk().Disks) != 2 || s.Disk().Disks["sda"] == nil || s.Disk().Disks["nvme0n1"] == nil {
+		t.Fatalf("disks %v, want sda and nvme0n1", s.Disk().Disks)
+	}
+	counters := c.last["sda"]
+	if counters.read.last != 2000000*512 || counters.written.last != 4000000*512 {
+		t.Errorf("sda read %d and wrote %d bytes", counters.read.last, counters.written.last)
+	}
+	// nothing changed between the two readings
+	if sda := s.Disk().Disks["sda"]; sda.Read.Len() != 1 || sda.Read.Last() != 0 {
+		t.Errorf("sda read rate %v, want [0]", sda.Read.Newest(-1))
+	}
+	if name := s.Disk().Disks["nvme0n1"].Write.Name(); name != "disk.nvme0n1.write" {
+		t.Errorf("series name %q", name)
+	}
+
+	fs := s.Disk().Filesystems["/"]
+	if fs == nil || fs.Used.Name() != "fs.root.used" || fs.Used.Len() != 2 {
+		t.Fatalf("filesystem / is %+v", fs)
+	}
+
+	c.SetMounts(nil)
+	if err := c.Collect(s); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Disk().Filesystems) != 0 {
+		t.Errorf("filesystems %v after removing every mount", s.Disk().Filesystems)
+	}
+}
+
+func TestFilesystemName(t *testing.T) {
+	for mount, want := range map[string]string{
+		"/":          "root",
+		"/home":      "home",
+		"/home/":     "home",
+		"/mnt/data":  "mnt_data",
+		"/media/a.b": "media_a_b",
+	} {
+		if got := FilesystemName(mount); got != want {
+			t.Errorf("FilesystemName(%q) = %q, want %q", mount, got, want)
+		}
+	}
+}
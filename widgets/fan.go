@@ -0,0 +1,170 @@
+package widgets
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+func init() {
+	RegisterCollector("fan", func() Collector {
+		return &FanCollector{
+			Sources: []FanSource{
+				&ThinkpadFanSource{},
+				NewHwmonFanSource(),
+			},
+		}
+	})
+}
+
+// Fan is the speed of a single fan.
+type Fan struct {
+	Name string
+	RPM  int
+}
+
+// FanSpeed is the state of the FanCollector.
+type FanSpeed struct {
+	// RPM is the speed of the fastest fan.
+	RPM *Series[int]
+	// Level is empty when the fan source has no notion of levels.
+	Level string
+	// ValueMin and ValueMax are the fixed bounds fan speeds are clamped
+	// to, so the fan graph has a stable scale.
+	ValueMin int
+	ValueMax int
+	Fans     []Fan
+}
+
+func newFanSpeed() *FanSpeed {
+	return &FanSpeed{RPM: NewSeries[int]("fan", SeriesCapacity), ValueMin: 0, ValueMax: 10000}
+}
+
+func (f *FanSpeed) Metrics() []Metric { return []Metric{f.RPM} }
+
+func (f *FanSpeed) Clone() State {
+	c := *f
+	c.RPM = f.RPM.Clone()
+	c.Fans = append([]Fan(nil), f.Fans...)
+	return &c
+}
+
+// Fan returns the state of the fan collector.
+func (s *Sample) Fan() *FanSpeed { return stateOf(s, "fan", newFanSpeed) }
+
+// FanSource is a backend that can read fan speeds. Level is left empty
+// when the backend does not expose a fan level.
+type FanSource interface {
+	Name() string
+	Read() (fans []Fan, level string, err error)
+}
+
+// FanCollector reports the fans of the first source that has any. The
+// ThinkPad source comes first because thinkpad_acpi also registers its
+// fan with hwmon, but only the ACPI file knows the fan level.
+type FanCollector struct {
+	Sources []FanSource
+}
+
+func (c *FanCollector) Name() string            { return "fan" }
+func (c *FanCollector) Interval() time.Duration { return time.Second * 5 }
+
+func (c *FanCollector) Collect(s *Sample) error {
+	var fans []Fan
+	var level string
+	err := fmt.Errorf("no fan sources")
+	for _, source := range c.Sources {
+		if fans, level, err = source.Read(); err == nil && len(fans) > 0 {
+			break
+		}
+	}
+
+	f := ensureState(s, "fan", newFanSpeed)
+	f.Fans = fans
+	f.Level = level
+
+	// nothing is pushed without a reading, a made up 0 would show up in
+	// the graph and in the history
+	if len(fans) == 0 {
+		if err == nil {
+			err = fmt.Errorf("no fans found")
+		}
+		return err
+	}
+
+	rpm := 0
+	for _, fan := range fans {
+		if fan.RPM > rpm {
+			rpm = fan.RPM
+		}
+	}
+
+	if rpm > f.ValueMax {
+		rpm = f.ValueMax
+	}
+
+	if rpm < f.ValueMin {
+		rpm = f.ValueMin
+	}
+
+	f.RPM.Push(rpm)
+	return nil
+}
+
+var fanRegexp *regexp.Regexp = regexp.MustCompile("speed:\t\t(\\d+)\nlevel:\t\t(.+)")
+
+// ThinkpadFanSource reads the thinkpad_acpi fan file.
+type ThinkpadFanSource struct{}
+
+func (f *ThinkpadFanSource) Name() string { return "thinkpad" }
+
+func (f *ThinkpadFanSource) Read() ([]Fan, string, error) {
+	buf, err := ReadFile("/proc/acpi/ibm/fan")
+	if err != nil {
+		return nil, "", err
+	}
+
+	m := fanRegexp.FindStringSubmatch(string(buf))
+	if len(m) != 3 {
+		return nil, "", fmt.Errorf("unexpected /proc/acpi/ibm/fan format")
+	}
+
+	rpm, _ := strconv.Atoi(m[1])
+	level := m[2]
+	if level == "disengaged" || level == "full-speed" {
+		level = "8"
+	}
+	return []Fan{{Name: "fan1", RPM: rpm}}, level, nil
+}
+
+// HwmonFanSource reads every /sys/class/hwmon/*/fan*_input.
+type HwmonFanSource struct {
+	inputs []hwmonInput
+}
+
+// NewHwmonFanSource discovers the hwmon fans.
+func NewHwmonFanSource() *HwmonFanSource {
+	return &HwmonFanSource{inputs: discoverHwmon("fan")}
+}
+
+func (f *HwmonFanSource) Name() string { return "hwmon" }
+
+func (f *HwmonFanSource) Read() ([]Fan, string, error) {
+	var fans []Fan
+	for _, input := range f.inputs {
+		buf, err := ReadString(input.path)
+		if err != nil {
+			continue
+		}
+		rpm, err := strconv.Atoi(buf)
+		if err != nil {
+			continue
+		}
+		fans = append(fans, Fan{Name: input.chip + "/" + input.label, RPM: rpm})
+	}
+	if len(fans) == 0 {
+		return nil, "", fmt.Errorf("no hwmon fans found")
+	}
+	return fans, "", nil
+}
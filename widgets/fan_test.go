@@ -0,0 +1,64 @@
+package widgets
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFanSources(t *testing.T) {
+	useRoot(t, fixtureRoot)
+
+	fans, level, err := (&ThinkpadFanSource{}).Read()
+	if err != nil || len(fans) != 1 || fans[0].RPM != 2400 || level != "auto" {
+		t.Errorf("thinkpad fans %v, level %q, error %v", fans, level, err)
+	}
+
+	fans, level, err = NewHwmonFanSource().Read()
+	want := []Fan{{Name: "thinkpad/fan1", RPM: 2400}}
+	if err != nil || !reflect.DeepEqual(fans, want) || level != "" {
+		t.Errorf("hwmon fans %v, level %q, error %v", fans, level, err)
+	}
+}
+
+func TestFanCollector(t *testing.T) {
+	tests := []struct {
+		name  string
+		root  string
+		rpm   int
+		level string
+	}{
+		{"thinkpad", fixtureRoot, 2400, "auto"},
+		{"hwmon only", writeFixture(t, map[string]string{
+			"/sys/class/hwmon/hwmon0/name":       "dell_smm\n",
+			"/sys/class/hwmon/hwmon0/fan1_input": "1200\n",
+			"/sys/class/hwmon/hwmon0/fan2_input": "3100\n",
+		}), 3100, ""},
+		{"clamped", writeFixture(t, map[string]string{
+			"/sys/class/hwmon/hwmon0/fan1_input": "65535\n",
+		}), 10000, ""},
+		{"no fans", t.TempDir(), 0, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useRoot(t, test.root)
+			c := &FanCollector{Sources: []FanSource{&ThinkpadFanSource{}, NewHwmonFanSource()}}
+			s := &Sample{}
+			err := c.Collect(s)
+			if test.rpm == 0 {
+				// without a reading nothing is pushed
+				if err == nil || s.Fan().RPM.Len() != 0 {
+					t.Errorf("pushed %v, error %v", s.Fan().RPM.Newest(-1), err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			fan := s.Fan()
+			if fan.RPM.Last() != test.rpm || fan.Level != test.level {
+				t.Errorf("fan at %d, level %q, want %d, %q", fan.RPM.Last(), fan.Level, test.rpm, test.level)
+			}
+		})
+	}
+}
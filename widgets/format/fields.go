@@ -0,0 +1,154 @@
+package format
+
+import (
+	"path"
+	"strings"
+
+	"github.com/lian/gonky/widgets"
+)
+
+type value struct {
+	num      float64
+	str      string
+	isString bool
+}
+
+func number(v float64) value { return value{num: v} }
+
+func boolean(b bool) value {
+	if b {
+		return value{num: 1}
+	}
+	return value{}
+}
+
+func str(s string) value { return value{str: s, isString: true} }
+
+// known reports whether name is one of Fields or a series a collector
+// may keep.
+func known(name string) bool {
+	for _, f := range Fields {
+		if ok, _ := path.Match(strings.ReplaceAll(f, "<interface>", "*"), name); ok {
+			return true
+		}
+	}
+	return widgets.IsMetricName(name)
+}
+
+// lookup resolves a field name. ok is false for fields that have no value
+// right now, like the battery on a desktop.
+func lookup(env *Env, name string) (value, bool) {
+	s := env.Sample
+	if s == nil {
+		return value{}, false
+	}
+
+	switch name {
+	case "temp":
+		return number(float64(s.Thermal().Value.Last())), true
+	case "fan.level":
+		return str(s.Fan().Level), true
+	case "ac":
+		b := s.Battery()
+		return boolean(b == nil || b.Status == nil || b.Status.OnAC), true
+	case "battery":
+		return boolean(battery(s) != nil), true
+	}
+
+	if strings.HasPrefix(name, "battery.") || name == "charging" || name == "discharging" {
+		return batteryField(s, name)
+	}
+
+	if strings.HasPrefix(name, "net.") {
+		parts := strings.SplitN(name, ".", 3)
+		if len(parts) == 3 {
+			iface := interfaceName(env, parts[1])
+			if v, ok := interfaceField(s, iface, parts[2]); ok {
+				return v, true
+			}
+			name = "net." + iface + "." + parts[2]
+		}
+	}
+
+	if m := s.Metric(name); m != nil {
+		return number(m.Latest()), true
+	}
+	return value{}, false
+}
+
+func battery(s *widgets.Sample) *widgets.BatteryStatus {
+	b := s.Battery()
+	if b == nil || b.Status == nil {
+		return nil
+	}
+	return b.Status.Battery
+}
+
+func batteryField(s *widgets.Sample, name string) (value, bool) {
+	b := battery(s)
+	if b == nil {
+		return value{}, false
+	}
+	switch name {
+	case "charging":
+		return boolean(b.Status == "Charging"), true
+	case "discharging":
+		return boolean(b.Status == "Discharging"), true
+	case "battery.status":
+		return str(strings.ToLower(b.Status)), true
+	case "battery.percent":
+		return number(b.Percent), true
+	case "battery.watts":
+		return number(b.Watts), true
+	case "battery.remaining":
+		// seconds, from the collector's estimate while there is one
+		if b.Status != "Charging" && b.Status != "Discharging" {
+			return value{}, false
+		}
+		if estimate := s.Battery().Estimate; estimate > 0 {
+			return number(estimate.Seconds()), true
+		}
+		if b.Watts <= 0 {
+			return value{}, false
+		}
+		left := b.Capacity
+		if b.Status == "Charging" {
+			left = b.CapacityFull - b.Capacity
+		}
+		return number(left / b.Watts * 3600), true
+	}
+	return value{}, false
+}
+
+// interfaceName resolves an interface alias like "wifi".
+func interfaceName(env *Env, alias string) string {
+	if _, ok := env.Sample.Network()[alias]; ok {
+		return alias
+	}
+	for name, a := range env.NetworkNames {
+		if a == alias {
+			return name
+		}
+	}
+	return alias
+}
+
+func interfaceField(s *widgets.Sample, name, field string) (value, bool) {
+	iface, ok := s.Network()[name]
+	if !ok {
+		return value{}, false
+	}
+	switch field {
+	case "up":
+		return boolean(iface.Carrier), true
+	case "state":
+		return str(iface.OperState), true
+	case "ip":
+		if len(iface.IPv4) == 0 {
+			return value{}, false
+		}
+		ip, _, _ := strings.Cut(iface.IPv4[0], "/")
+		return str(ip), true
+	}
+	return value{}, false
+}
@@ -0,0 +1,360 @@
+// Package format implements the status bar templates, format strings like
+//
+//	{time:15:04}{right}{cpu:%.0f}% {temp}C | {net.wifi.rx:human}/s{?discharging} | bat {battery.remaining:duration}{/}
+//
+// A template is parsed once and then evaluated against every new Sample.
+//
+// {name} or {name:format} is replaced by a field. Fields are the metric
+// series by name, like "cpu", "load.1" or "disk.sda.read", and the fields
+// listed in Fields. Network interfaces can be named by their alias, so
+// "net.wifi.rx" works as well as "net.wlp3s0.rx". Unknown fields are an
+// error, fields without a value right now are empty. The format is a printf verb for a float like "%.1f" or "%5.2g",
+// "%d" for the rounded value, "human" for byte sizes, "int", "duration"
+// for seconds as h:mm, or a time layout for "time". Text fields keep the
+// width of a printf verb.
+//
+// {?cond}...{/} is only shown when cond holds, {?cond}...{else}...{/}
+// shows the other part otherwise. cond is a field that is true when it is
+// non-zero or non-empty, optionally negated with "!" or compared like
+// "cpu>80", "cpu<5" or "battery.status=charging".
+//
+// {left}, {center} and {right} start a section of the bar, the text
+// before the first of them is on the left. "{{" is a literal "{".
+package format
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lian/gonky/widgets"
+)
+
+// Sections of the status bar.
+const (
+	Left = iota
+	Center
+	Right
+)
+
+// Fields are the fields besides the metric series.
+var Fields = []string{
+	"time",
+	"temp",
+	"fan.level",
+	"ac", "battery", "charging", "discharging",
+	"battery.status", "battery.percent", "battery.watts", "battery.remaining",
+	"net.<interface>.up", "net.<interface>.state", "net.<interface>.ip",
+}
+
+// Env is what a template is evaluated against.
+type Env struct {
+	Sample *widgets.Sample
+	Now    time.Time
+	// NetworkNames maps interface names to aliases, the same way as
+	// status.NetworkNamesMap.
+	NetworkNames map[string]string
+}
+
+// Template is a parsed format string.
+type Template struct {
+	sections [3][]node
+}
+
+type node interface {
+	eval(env *Env, b *strings.Builder)
+}
+
+type text string
+
+type field struct {
+	name   string
+	format string
+}
+
+type condition struct {
+	name    string
+	not     bool
+	op      byte
+	operand string
+
+	then      []node
+	otherwise []node
+}
+
+// Parse parses a format string.
+func Parse(src string) (*Template, error) {
+	t := &Template{}
+	p := &parser{src: src}
+	section := Left
+	for {
+		nodes, end, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		t.sections[section] = append(t.sections[section], nodes...)
+		switch end {
+		case "":
+			return t, nil
+		case "left":
+			section = Left
+		case "center":
+			section = Center
+		case "right":
+			section = Right
+		default:
+			return nil, fmt.Errorf("{%s} without {?...}", end)
+		}
+	}
+}
+
+// MustParse is Parse for format strings known to be valid.
+func MustParse(src string) *Template {
+	t, err := Parse(src)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+type parser struct {
+	src string
+	pos int
+}
+
+// parse reads nodes up to the end of the input or a tag that ends the
+// current block, which it returns: "else", "/" or a section.
+func (p *parser) parse() ([]node, string, error) {
+	var nodes []node
+	var buf strings.Builder
+	flush := func() {
+		if buf.Len() > 0 {
+			nodes = append(nodes, text(buf.String()))
+			buf.Reset()
+		}
+	}
+
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c != '{' {
+			buf.WriteByte(c)
+			p.pos++
+			continue
+		}
+		if strings.HasPrefix(p.src[p.pos:], "{{") {
+			buf.WriteByte('{')
+			p.pos += 2
+			continue
+		}
+
+		end := strings.IndexByte(p.src[p.pos:], '}')
+		if end < 0 {
+			return nil, "", fmt.Errorf("unclosed { at %d", p.pos)
+		}
+		tag := p.src[p.pos+1 : p.pos+end]
+		start := p.pos
+		p.pos += end + 1
+		flush()
+
+		switch {
+		case tag == "":
+			return nil, "", fmt.Errorf("empty {} at %d", start)
+		case tag == "else" || tag == "/" || tag == "left" || tag == "center" || tag == "right":
+			return nodes, tag, nil
+		case tag[0] == '?':
+			cond, err := parseCondition(tag[1:])
+			if err != nil {
+				return nil, "", fmt.Errorf("%v at %d", err, start)
+			}
+			var closing string
+			cond.then, closing, err = p.parse()
+			if err != nil {
+				return nil, "", err
+			}
+			if closing == "else" {
+				cond.otherwise, closing, err = p.parse()
+				if err != nil {
+					return nil, "", err
+				}
+			}
+			switch closing {
+			case "/":
+			case "":
+				return nil, "", fmt.Errorf("{?%s} at %d is not closed with {/}", tag[1:], start)
+			default:
+				return nil, "", fmt.Errorf("{%s} inside {?%s} at %d", closing, tag[1:], start)
+			}
+			nodes = append(nodes, cond)
+		default:
+			name, format, _ := strings.Cut(tag, ":")
+			if !known(name) {
+				return nil, "", fmt.Errorf("{%s} at %d: unknown field %q", tag, start, name)
+			}
+			if err := checkFormat(name, format); err != nil {
+				return nil, "", fmt.Errorf("{%s} at %d: %v", tag, start, err)
+			}
+			nodes = append(nodes, field{name: name, format: format})
+		}
+	}
+	flush()
+	return nodes, "", nil
+}
+
+func parseCondition(expr string) (*condition, error) {
+	c := &condition{}
+	if strings.HasPrefix(expr, "!") {
+		c.not = true
+		expr = expr[1:]
+	}
+	if i := strings.IndexAny(expr, "<>="); i >= 0 {
+		c.op = expr[i]
+		c.operand = expr[i+1:]
+		expr = expr[:i]
+		if c.op != '=' {
+			if _, err := strconv.ParseFloat(c.operand, 64); err != nil {
+				return nil, fmt.Errorf("%q is not a number", c.operand)
+			}
+		}
+	}
+	if expr == "" {
+		return nil, fmt.Errorf("condition without a field")
+	}
+	if !known(expr) {
+		return nil, fmt.Errorf("unknown field %q", expr)
+	}
+	c.name = expr
+	return c, nil
+}
+
+// printfFormat matches the printf formats of fields: flags and width,
+// precision and the verb. Values are float64, so only the float verbs and
+// %d, which rounds, make sense.
+var printfFormat = regexp.MustCompile(`^%([-+# 0]*[0-9]*)(\.[0-9]+)?([fFeEgGvd])$`)
+
+func checkFormat(name, format string) error {
+	switch {
+	case format == "", name == "time":
+		return nil
+	case format == "human", format == "int", format == "duration":
+		return nil
+	case strings.HasPrefix(format, "%"):
+		if !printfFormat.MatchString(format) {
+			return fmt.Errorf("unsupported format %q, want one %%f, %%e, %%g, %%v or %%d verb", format)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// Execute evaluates the template and returns the text of each section.
+func (t *Template) Execute(env *Env) [3]string {
+	var sections [3]string
+	for i, nodes := range t.sections {
+		var b strings.Builder
+		for _, n := range nodes {
+			n.eval(env, &b)
+		}
+		sections[i] = b.String()
+	}
+	return sections
+}
+
+func (t text) eval(env *Env, b *strings.Builder) {
+	b.WriteString(string(t))
+}
+
+func (f field) eval(env *Env, b *strings.Builder) {
+	if f.name == "time" {
+		layout := f.format
+		if layout == "" {
+			layout = "15:04 02.01.2006"
+		}
+		b.WriteString(env.Now.Format(layout))
+		return
+	}
+
+	v, ok := lookup(env, f.name)
+	if !ok {
+		return
+	}
+	if v.isString {
+		if m := printfFormat.FindStringSubmatch(f.format); m != nil {
+			fmt.Fprintf(b, "%"+m[1]+"s", v.str)
+		} else {
+			b.WriteString(v.str)
+		}
+		return
+	}
+	b.WriteString(formatNumber(v.num, f.format))
+}
+
+func (c *condition) eval(env *Env, b *strings.Builder) {
+	nodes := c.otherwise
+	if c.holds(env) {
+		nodes = c.then
+	}
+	for _, n := range nodes {
+		n.eval(env, b)
+	}
+}
+
+func (c *condition) holds(env *Env) bool {
+	v, ok := lookup(env, c.name)
+	var holds bool
+	switch c.op {
+	case 0:
+		holds = ok && (v.isString && v.str != "" || !v.isString && v.num != 0)
+	case '=':
+		if v.isString {
+			holds = ok && v.str == c.operand
+		} else {
+			operand, err := strconv.ParseFloat(c.operand, 64)
+			holds = ok && err == nil && v.num == operand
+		}
+	default:
+		operand, _ := strconv.ParseFloat(c.operand, 64)
+		if c.op == '<' {
+			holds = ok && !v.isString && v.num < operand
+		} else {
+			holds = ok && !v.isString && v.num > operand
+		}
+	}
+	return holds != c.not
+}
+
+func formatNumber(v float64, format string) string {
+	switch {
+	case format == "human":
+		return Human(v)
+	case format == "int":
+		return strconv.FormatFloat(math.Round(v), 'f', 0, 64)
+	case format == "duration":
+		minutes := int(v / 60)
+		return fmt.Sprintf("%d:%.2d", minutes/60, minutes%60)
+	case strings.HasPrefix(format, "%"):
+		if strings.HasSuffix(format, "d") {
+			return fmt.Sprintf(format, int64(math.Round(v)))
+		}
+		return fmt.Sprintf(format, v)
+	case v == math.Trunc(v):
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
+
+// Human formats a number of bytes with a binary unit, like "1.5M".
+func Human(v float64) string {
+	units := []string{"B", "K", "M", "G", "T", "P"}
+	i := 0
+	for math.Abs(v) >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f%s", v, units[i])
+	}
+	return fmt.Sprintf("%.1f%s", v, units[i])
+}
@@ -0,0 +1,137 @@
+package format
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lian/gonky/widgets"
+)
+
+func testEnv() *Env {
+	s := &widgets.Sample{}
+	floats := func(name string) *widgets.Series[float64] { return widgets.NewSeries[float64](name, 10) }
+	ints := func(name string) *widgets.Series[int] { return widgets.NewSeries[int](name, 10) }
+
+	cpu := &widgets.CpuUsage{Total: floats("cpu")}
+	cpu.Total.Push(42.4)
+	s.SetState("cpu", cpu)
+
+	thermal := &widgets.Thermal{Value: ints("thermal")}
+	thermal.Value.Push(55)
+	s.SetState("thermal", thermal)
+
+	load := &widgets.Load{
+		Load1:           floats("load.1"),
+		Load5:           floats("load.5"),
+		Load15:          floats("load.15"),
+		TasksRunning:    ints("tasks.running"),
+		TasksTotal:      ints("tasks.total"),
+		ContextSwitches: floats("ctxt"),
+		Interrupts:      floats("intr"),
+	}
+	load.Load1.Push(1.5)
+	load.TasksRunning.Push(3)
+	s.SetState("load", load)
+
+	s.SetState("battery", &widgets.Battery{
+		Status: &widgets.PowerStatus{
+			Battery: &widgets.BatteryStatus{Status: "Discharging", Percent: 80.6, Watts: 10, Capacity: 40},
+		},
+		Level: floats("battery.level"),
+		Power: floats("battery.power"),
+	})
+	return &Env{Sample: s, Now: time.Date(2024, 3, 1, 14, 5, 0, 0, time.UTC)}
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		format string
+		want   [3]string
+	}{
+		{"plain text", [3]string{"plain text", "", ""}},
+		{"{{literal}", [3]string{"{literal}", "", ""}},
+		{"{cpu}", [3]string{"42.4", "", ""}},
+		{"{cpu:%.0f}%", [3]string{"42%", "", ""}},
+		{"{cpu:%5.1f}", [3]string{" 42.4", "", ""}},
+		{"{cpu:%e}", [3]string{"4.240000e+01", "", ""}},
+		{"{cpu:%g}", [3]string{"42.4", "", ""}},
+		{"{cpu:%v}", [3]string{"42.4", "", ""}},
+		{"{cpu:%d}", [3]string{"42", "", ""}},
+		{"{tasks.running:%d}", [3]string{"3", "", ""}},
+		{"{temp:%d}C", [3]string{"55C", "", ""}},
+		{"{temp:%3d}C", [3]string{" 55C", "", ""}},
+		{"{cpu:int}", [3]string{"42", "", ""}},
+		{"{load.1}", [3]string{"1.5", "", ""}},
+		{"{cpu:human}", [3]string{"42B", "", ""}},
+		{"{battery.remaining:duration}", [3]string{"4:00", "", ""}},
+		{"{battery.status}", [3]string{"discharging", "", ""}},
+		{"{battery.status:%12v}", [3]string{" discharging", "", ""}},
+		{"{time:15:04}", [3]string{"14:05", "", ""}},
+		{"[{net.eth9.rx}]", [3]string{"[]", "", ""}},
+
+		{"{?discharging}bat{/}", [3]string{"bat", "", ""}},
+		{"{?charging}chg{/}", [3]string{"", "", ""}},
+		{"{?!charging}not{/}", [3]string{"not", "", ""}},
+		{"{?ac}ac{else}bat{/}", [3]string{"bat", "", ""}},
+		{"{?cpu>40}hot{else}ok{/}", [3]string{"hot", "", ""}},
+		{"{?cpu<40}low{else}high{/}", [3]string{"high", "", ""}},
+		{"{?battery.status=discharging}d{/}", [3]string{"d", "", ""}},
+		{"{?power.package-0}x{else}y{/}", [3]string{"y", "", ""}},
+		{"{?battery}b{?cpu>90}!{else}{cpu:%.0f}{/}{/}", [3]string{"b42", "", ""}},
+
+		{"a{right}b", [3]string{"a", "", "b"}},
+		{"a{center}b{right}c", [3]string{"a", "b", "c"}},
+		{"{right}r{left}l", [3]string{"l", "", "r"}},
+		{"{center}x{center}y", [3]string{"", "xy", ""}},
+	}
+	env := testEnv()
+	for _, test := range tests {
+		tmpl, err := Parse(test.format)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.format, err)
+			continue
+		}
+		if got := tmpl.Execute(env); got != test.want {
+			t.Errorf("Parse(%q).Execute() = %q, want %q", test.format, got, test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		format string
+		err    string
+	}{
+		{"{cpu", "unclosed {"},
+		{"{}", "empty {}"},
+		{"{/}", "{/} without {?...}"},
+		{"{else}", "{else} without {?...}"},
+		{"{?cpu}x", "is not closed with {/}"},
+		{"{?cpu}{?temp}x{/}", "is not closed with {/}"},
+		{"{?cpu}x{right}y{/}", "{right} inside {?cpu}"},
+		{"{?cpu}x{else}y{else}z{/}", "{else} inside {?cpu}"},
+		{"{?cpu>hot}x{/}", `"hot" is not a number`},
+		{"{?>5}x{/}", "condition without a field"},
+		{"{cpu:bogus}", `unknown format "bogus"`},
+		{"{cpuu}", `{cpuu} at 0: unknown field "cpuu"`},
+		{"{net.wifi}", `unknown field "net.wifi"`},
+		{"x{?!batery}y{/}", `unknown field "batery" at 1`},
+		{"{?tempp>80}hot{/}", `unknown field "tempp"`},
+		{"{cpu:%s}", "unsupported format"},
+		{"{cpu:%x}", "unsupported format"},
+		{"{cpu:%q}", "unsupported format"},
+		{"{cpu:%.1f%%}", "unsupported format"},
+		{"{cpu:%d %d}", "unsupported format"},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.format)
+		if err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error containing %q", test.format, test.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Parse(%q) = %v, want an error containing %q", test.format, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package widgets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Root is the directory that /proc and /sys paths are resolved against.
+// It defaults to "/" and can be pointed at a fixture tree or at the
+// location the host filesystem is mounted at inside a container.
+var Root string = "/"
+
+func init() {
+	if root := os.Getenv("GONKY_ROOT"); root != "" {
+		SetRoot(root)
+	}
+}
+
+// SetRoot changes Root. gopsutil does its own reads, so the root is
+// passed on to it through HOST_PROC and HOST_SYS as well. Reads gopsutil
+// does in its package init, like the first cpu times, still come from the
+// real /proc, see CPUCollector.
+func SetRoot(root string) {
+	Root = root
+	os.Setenv("HOST_PROC", HostPath("/proc"))
+	os.Setenv("HOST_SYS", HostPath("/sys"))
+}
+
+// HostPath joins the given absolute path elements onto Root.
+func HostPath(elem ...string) string {
+	return filepath.Join(append([]string{Root}, elem...)...)
+}
+
+// ReadFile reads the file at path below Root.
+func ReadFile(path string) ([]byte, error) {
+	return ioutil.ReadFile(HostPath(path))
+}
+
+// ReadDir reads the directory at path below Root.
+func ReadDir(path string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(HostPath(path))
+}
+
+// Exists reports whether path exists below Root.
+func Exists(path string) bool {
+	_, err := os.Stat(HostPath(path))
+	return err == nil
+}
+
+// Glob returns the paths below Root matching pattern. The returned paths
+// are absolute paths relative to Root again, so they can be passed on to
+// ReadFile.
+func Glob(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(HostPath(pattern))
+	if err != nil {
+		return nil, err
+	}
+	root := filepath.Clean(Root)
+	for i, m := range matches {
+		rel, err := filepath.Rel(root, m)
+		if err != nil {
+			return nil, err
+		}
+		matches[i] = "/" + rel
+	}
+	return matches, nil
+}
+
+// ReadString reads a file below Root and trims surrounding whitespace.
+func ReadString(path string) (string, error) {
+	buf, err := ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
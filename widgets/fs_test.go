@@ -0,0 +1,54 @@
+package widgets
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fixtureRoot is a snapshot of the /proc and /sys files of a laptop.
+const fixtureRoot = "testdata/laptop"
+
+// useRoot points Root, and gopsutil with it, at a fixture tree until the
+// test ends.
+func useRoot(t *testing.T, root string) {
+	t.Helper()
+	old := Root
+	t.Setenv("HOST_PROC", "")
+	t.Setenv("HOST_SYS", "")
+	SetRoot(root)
+	t.Cleanup(func() { Root = old })
+}
+
+// writeFixture creates a root holding files, keyed by their absolute
+// path below the root.
+func writeFixture(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for path, content := range files {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestGlob(t *testing.T) {
+	useRoot(t, fixtureRoot)
+	paths, err := Glob("/sys/class/hwmon/hwmon0/temp*_input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/sys/class/hwmon/hwmon0/temp1_input", "/sys/class/hwmon/hwmon0/temp2_input"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("Glob = %v, want %v", paths, want)
+	}
+	if s, err := ReadString(paths[0]); s != "45000" || err != nil {
+		t.Errorf("ReadString(%s) = %q, %v", paths[0], s, err)
+	}
+}
@@ -1,200 +1,321 @@
 package widgets
 
 import (
-	"io/ioutil"
-	"regexp"
-	"strconv"
-	"strings"
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
 	"time"
-
-	psutil_cpu "github.com/shirou/gopsutil/cpu"
-	psutil_mem "github.com/shirou/gopsutil/mem"
 )
 
-func NewStats() *Stats {
-	s := &Stats{
-		Updated:              make(chan bool),
-		FanGraphMaxCount:     60,
-		ThermalGraphMaxCount: 60,
-		MemoryGraphMaxCount:  60,
-		CpuGraphMaxCount:     60,
+// SeriesCapacity is the number of values every metric series keeps.
+var SeriesCapacity int = 60
 
-		ThermalValueMin: 0xffff,
-		//FanValueMin:     0xffff,
-		MemoryValueMin: 0xffff,
-		CpuValueMin:    0xffff,
+// Verbose logs every collection with its duration, not only new errors.
+var Verbose bool = false
 
-		FanValueMin: 0,
-		FanValueMax: 10000,
+func NewStats() *Stats {
+	s := &Stats{
+		Updated:     make(chan bool),
+		Collectors:  newCollectors(),
+		rescheduled: make(chan bool, 1),
 	}
+	s.sample = s.work.Clone()
 	return s
 }
 
+// Stats runs the collectors and owns the Sample they write to. Renderers
+// must only read the sample through Snapshot.
 type Stats struct {
 	Updated chan bool
 
-	ThermalValue         int
-	ThermalValueMax      int
-	ThermalValueMin      int
-	ThermalGraph         []int
-	ThermalGraphMaxCount int
-
-	FanLevel         int
-	FanValue         int
-	FanValueMax      int
-	FanValueMin      int
-	FanGraph         []int
-	FanGraphMaxCount int
+	Collectors []Collector
+
+	// schedMu guards the schedule set by Schedule, rescheduled wakes up
+	// Run to apply it.
+	schedMu     sync.Mutex
+	intervals   map[string]time.Duration
+	disabled    map[string]bool
+	rescheduled chan bool
+
+	// work is the sample the collectors write to, only the goroutine
+	// collecting touches it. Every finished collection is published as a
+	// copy to sample, so slow reads never block Snapshot.
+	work       Sample
+	mu         sync.RWMutex
+	sample     *Sample
+	lastErrors map[string]string
+
+	history   *History
+	lastFlush time.Time
+}
 
-	MemoryValue         float64
-	MemoryValueMax      float64
-	MemoryValueMin      float64
-	MemoryGraph         []float64
-	MemoryGraphMaxCount int
+// SetHistory restores the recorded history into the series and makes Run
+// append new values to it every HistoryInterval. It has to be called
+// before Run.
+func (s *Stats) SetHistory(h *History) {
+	s.history = h
+	h.restore(s.work.Metrics())
+	s.publish()
+}
 
-	CpuValue         float64
-	CpuValueMax      float64
-	CpuValueMin      float64
-	CpuGraph         []float64
-	CpuGraphMaxCount int
+// Flush writes the values collected since the last flush to the history.
+func (s *Stats) Flush() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.history == nil {
+		return nil
+	}
+	return s.history.Flush(s.sample.Metrics())
 }
 
-func (s *Stats) Run() {
-	s.UpdateMemory()
-	s.UpdateCPU()
-	s.UpdateThermal()
-	s.UpdateFan()
-	s.Updated <- true
-
-	five := time.NewTicker(time.Second * 5)
-	ten := time.NewTicker(time.Second * 10)
-	for {
-		select {
-		case <-five.C:
-			s.UpdateCPU()
-			s.UpdateThermal()
-			s.UpdateFan()
-			break
-		case <-ten.C:
-			s.UpdateMemory()
-			break
-		}
-		s.Updated <- true
+// Close flushes and closes the history.
+func (s *Stats) Close() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.history == nil {
+		return nil
 	}
+	return s.history.Close(s.sample.Metrics())
 }
 
-var fanRegexp *regexp.Regexp = regexp.MustCompile("speed:\t\t(\\d+)\nlevel:\t\t(.+)")
+// Snapshot returns a copy of the current sample. It only contains whole
+// collections and can be read without further locking.
+func (s *Stats) Snapshot() *Sample {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sample.Clone()
+}
 
-func (s *Stats) UpdateFan() {
-	var rpm int
-	var level int
-	var file string = "/proc/acpi/ibm/fan"
+// publish makes the work sample visible to Snapshot. The copy is taken
+// outside the lock, which is only held to swap it in.
+func (s *Stats) publish() {
+	sample := s.work.Clone()
+	s.mu.Lock()
+	s.sample = sample
+	s.mu.Unlock()
+}
 
-	if buf, err := ioutil.ReadFile(file); err == nil {
-		m := fanRegexp.FindStringSubmatch(string(buf))
-		if len(m) == 3 {
-			rpm, _ = strconv.Atoi(m[1])
-			if m[2] == "disengaged" {
-				level = 8
-			} else {
-				level, _ = strconv.Atoi(m[2])
-			}
-		}
-	}
+// State is what a collector keeps in a Sample between collections, like
+// its series. Clone has to copy everything the collector changes later on,
+// Snapshot hands the copy to renderers while the collecting goes on.
+type State interface {
+	Clone() State
+	Metrics() []Metric
+}
 
-	s.FanLevel = level
-	s.FanValue = rpm
+// Sample holds the states of the collectors, keyed by collector name.
+// Collectors add accessors for their own state, like Sample.Load.
+type Sample struct {
+	states map[string]State
+}
 
-	if s.FanValue > s.FanValueMax {
-		s.FanValue = s.FanValueMax
-	}
+// State returns the state stored by the named collector, or nil.
+func (s *Sample) State(name string) State {
+	return s.states[name]
+}
 
-	if s.FanValue < s.FanValueMin {
-		s.FanValue = s.FanValueMin
+// SetState stores the state of the named collector. A nil state removes
+// it.
+func (s *Sample) SetState(name string, state State) {
+	if state == nil {
+		delete(s.states, name)
+		return
 	}
-
-	if len(s.FanGraph) >= s.FanGraphMaxCount {
-		s.FanGraph = append(s.FanGraph[1:], s.FanValue)
-	} else {
-		s.FanGraph = append(s.FanGraph, s.FanValue)
+	if s.states == nil {
+		s.states = map[string]State{}
 	}
+	s.states[name] = state
 }
 
-func (s *Stats) UpdateThermal() {
-	var max uint64 = 0
-
-	sensors := []string{
-		"/sys/devices/platform/coretemp.0/hwmon/hwmon0/temp1_input",
-		"/sys/devices/platform/coretemp.0/hwmon/hwmon0/temp2_input",
-		"/sys/devices/platform/coretemp.0/hwmon/hwmon0/temp3_input",
+// stateOf returns the state stored under name, or empty() when there is
+// none. The empty state is not stored, readers must not change a Sample.
+func stateOf[T State](s *Sample, name string, empty func() T) T {
+	if state, ok := s.states[name].(T); ok {
+		return state
 	}
+	return empty()
+}
 
-	for _, path := range sensors {
-		if buf, err := ioutil.ReadFile(path); err == nil {
-			str := strings.Replace(string(buf), "\n", "", -1)
-			value, err := strconv.ParseUint(str, 10, 64)
-			if err == nil && value > max {
-				max = value
-			}
-		}
+// ensureState returns the state stored under name, storing create() first
+// when there is none yet.
+func ensureState[T State](s *Sample, name string, create func() T) T {
+	if state, ok := s.states[name].(T); ok {
+		return state
 	}
+	state := create()
+	s.SetState(name, state)
+	return state
+}
 
-	s.ThermalValue = int(max / 1000)
-
-	if s.ThermalValue > s.ThermalValueMax {
-		s.ThermalValueMax = s.ThermalValue
+// Clone returns a deep copy of the sample.
+func (s *Sample) Clone() *Sample {
+	c := &Sample{states: make(map[string]State, len(s.states))}
+	for name, state := range s.states {
+		c.states[name] = state.Clone()
 	}
+	return c
+}
 
-	if s.ThermalValue < s.ThermalValueMin {
-		s.ThermalValueMin = s.ThermalValue
+// Metrics returns every series of the sample, ordered by collector name.
+func (s *Sample) Metrics() []Metric {
+	names := make([]string, 0, len(s.states))
+	for name := range s.states {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	if len(s.ThermalGraph) >= s.ThermalGraphMaxCount {
-		s.ThermalGraph = append(s.ThermalGraph[1:], s.ThermalValue)
-	} else {
-		s.ThermalGraph = append(s.ThermalGraph, s.ThermalValue)
+	var metrics []Metric
+	for _, name := range names {
+		metrics = append(metrics, s.states[name].Metrics()...)
 	}
+	return metrics
 }
 
-func (s *Stats) UpdateMemory() {
-	v, _ := psutil_mem.VirtualMemory()
-	s.MemoryValue = v.UsedPercent
+// MarshalJSON encodes the states by collector name.
+func (s *Sample) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.states)
+}
 
-	if s.MemoryValue > s.MemoryValueMax {
-		s.MemoryValueMax = s.MemoryValue
+// Metric returns the series with the given name, or nil.
+func (s *Sample) Metric(name string) Metric {
+	for _, m := range s.Metrics() {
+		if m.Name() == name {
+			return m
+		}
 	}
+	return nil
+}
 
-	if s.MemoryValue < s.MemoryValueMin {
-		s.MemoryValueMin = s.MemoryValue
+// Schedule overrides the Interval of collectors by name and sets the
+// collectors that are not run at all. It can be called while Run is
+// running, the series collected so far are kept.
+func (s *Stats) Schedule(intervals map[string]time.Duration, disabled map[string]bool) {
+	s.schedMu.Lock()
+	s.intervals, s.disabled = intervals, disabled
+	s.schedMu.Unlock()
+
+	select {
+	case s.rescheduled <- true:
+	default:
 	}
+}
 
-	if len(s.MemoryGraph) >= s.MemoryGraphMaxCount {
-		s.MemoryGraph = append(s.MemoryGraph[1:], s.MemoryValue)
-	} else {
-		s.MemoryGraph = append(s.MemoryGraph, s.MemoryValue)
-	}
+func (s *Stats) schedule() (map[string]time.Duration, map[string]bool) {
+	s.schedMu.Lock()
+	defer s.schedMu.Unlock()
+	return s.intervals, s.disabled
 }
 
-func (s *Stats) UpdateCPU() {
-	//info, _ := psutil_cpu.Info(); spew.Dump(info)
-	percent, err := psutil_cpu.Percent(0, false)
-	if err != nil || len(percent) != 1 {
+// Run polls every collector once and then each one again whenever its
+// interval has passed. Collectors that become due at the same time are
+// collected together and announced with a single Updated event.
+func (s *Stats) Run() {
+	if len(s.Collectors) == 0 {
 		return
 	}
 
-	s.CpuValue = percent[0]
+	// last is when each collector was due the last time, so the schedule
+	// does not drift by the time the collections take
+	last := make([]time.Time, len(s.Collectors))
+	polled := make([]time.Duration, len(s.Collectors))
+	s.lastFlush = time.Now()
+	timer := time.NewTimer(time.Hour)
+	for {
+		now := time.Now()
+		intervals, disabled := s.schedule()
+		collected := false
+		wait := time.Hour
+		for i, c := range s.Collectors {
+			if disabled[c.Name()] {
+				continue
+			}
+			interval := c.Interval()
+			if d, ok := intervals[c.Name()]; ok && d > 0 {
+				interval = d
+			}
+			if interval != polled[i] {
+				if setter, ok := c.(IntervalSetter); ok {
+					setter.SetInterval(interval)
+				}
+				polled[i] = interval
+			}
+
+			due := last[i].Add(interval)
+			if last[i].IsZero() || !due.After(now) {
+				s.collect(c)
+				collected = true
+				if last[i].IsZero() || now.Sub(due) >= interval {
+					due = now
+				}
+				last[i] = due
+			}
+			if next := last[i].Add(interval).Sub(now); next < wait {
+				wait = next
+			}
+		}
 
-	if s.CpuValue > s.CpuValueMax {
-		s.CpuValueMax = s.CpuValue
+		if collected {
+			s.Updated <- true
+		}
+		if s.history != nil && now.Sub(s.lastFlush) >= HistoryInterval {
+			if err := s.Flush(); err != nil {
+				log.Println("history:", err)
+			}
+			s.lastFlush = now
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+		case <-s.rescheduled:
+		}
 	}
+}
 
-	if s.CpuValue < s.CpuValueMin {
-		s.CpuValueMin = s.CpuValue
+// Collect runs every enabled collector once. It is meant for one-off
+// readings without Run and must not be called while Run is running, rates
+// need two calls to show up.
+func (s *Stats) Collect() {
+	_, disabled := s.schedule()
+	for _, c := range s.Collectors {
+		if !disabled[c.Name()] {
+			s.collect(c)
+		}
 	}
+}
+
+func (s *Stats) collect(c Collector) {
+	start := time.Now()
+	err := c.Collect(&s.work)
+	if s.history != nil {
+		// series that only appear once collected, like the cpu cores,
+		// get their history back right away
+		s.history.restore(s.work.Metrics())
+	}
+	s.publish()
 
-	if len(s.CpuGraph) >= s.CpuGraphMaxCount {
-		s.CpuGraph = append(s.CpuGraph[1:], s.CpuValue)
-	} else {
-		s.CpuGraph = append(s.CpuGraph, s.CpuValue)
+	if Verbose {
+		log.Printf("collector %s: %v, error: %v", c.Name(), time.Since(start), err)
+	}
+
+	// only log when the error changes, a missing sensor would otherwise
+	// show up on every tick
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	if s.lastErrors == nil {
+		s.lastErrors = map[string]string{}
+	}
+	if msg != s.lastErrors[c.Name()] && msg != "" {
+		log.Printf("collector %s: %s", c.Name(), msg)
 	}
+	s.lastErrors[c.Name()] = msg
 }
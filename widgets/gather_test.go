@@ -0,0 +1,288 @@
+package widgets
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestSampleClone checks that no collector shares series with the clones
+// Snapshot hands out.
+func TestSampleClone(t *testing.T) {
+	useRoot(t, fixtureRoot)
+	sensors := DiscoverTemperatureSensors()
+	collectors := []Collector{
+		&ThermalCollector{source: ThermalMax, Sensors: sensors},
+		&FanCollector{Sources: []FanSource{&ThinkpadFanSource{}}},
+		&LoadCollector{},
+		&PressureCollector{},
+		&NetworkCollector{last: map[string]*netCounters{}},
+		&DiskCollector{mounts: []string{"/"}, last: map[string]*diskCounters{}},
+		&BatteryCollector{},
+	}
+
+	s := &Sample{}
+	collect := func() {
+		for _, c := range collectors {
+			if err := c.Collect(s); err != nil {
+				t.Fatalf("%s: %v", c.Name(), err)
+			}
+		}
+	}
+	collect()
+	collect()
+
+	clone := s.Clone()
+	counts := map[string]int{}
+	for _, m := range clone.Metrics() {
+		counts[m.Name()] = len(m.Floats(-1))
+	}
+	for _, c := range collectors {
+		if clone.State(c.Name()) == nil {
+			t.Errorf("%s: no state in the clone", c.Name())
+		}
+	}
+
+	collect()
+	for _, m := range clone.Metrics() {
+		if n := len(m.Floats(-1)); n != counts[m.Name()] {
+			t.Errorf("%s: the clone changed from %d to %d values", m.Name(), counts[m.Name()], n)
+		}
+	}
+	if len(s.Metrics()) != len(counts) {
+		t.Errorf("%d series, the clone has %d", len(s.Metrics()), len(counts))
+	}
+}
+
+func TestSampleState(t *testing.T) {
+	s := &Sample{}
+	// readers get an empty state without storing it
+	if s.Load().Load1.Len() != 0 || s.State("load") != nil {
+		t.Error("reading the load stored a state")
+	}
+	if s.Battery() != nil || s.Network() != nil {
+		t.Error("optional states are not nil before they are collected")
+	}
+
+	l := ensureState(s, "load", newLoad)
+	l.Load1.Push(1)
+	if s.Load() != l || len(s.Metrics()) != len(l.Metrics()) {
+		t.Error("the stored load is not returned")
+	}
+	s.SetState("load", nil)
+	if s.State("load") != nil || len(s.Metrics()) != 0 {
+		t.Error("the load is still stored after removing it")
+	}
+}
+
+// pairState is written by pairCollector, which pushes to both series in
+// every collection.
+type pairState struct {
+	A *Series[float64]
+	B *Series[float64]
+}
+
+func (p *pairState) Metrics() []Metric { return []Metric{p.A, p.B} }
+
+func (p *pairState) Clone() State {
+	return &pairState{A: p.A.Clone(), B: p.B.Clone()}
+}
+
+type pairCollector struct {
+	name     string
+	interval time.Duration
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (c *pairCollector) Name() string            { return c.name }
+func (c *pairCollector) Interval() time.Duration { return c.interval }
+
+func (c *pairCollector) Collect(s *Sample) error {
+	c.mu.Lock()
+	c.calls++
+	calls := c.calls
+	c.mu.Unlock()
+
+	p := ensureState(s, c.name, func() *pairState {
+		return &pairState{A: NewSeries[float64](c.name+".a", 10), B: NewSeries[float64](c.name+".b", 10)}
+	})
+	p.A.Push(float64(calls))
+	time.Sleep(100 * time.Microsecond)
+	p.B.Push(float64(calls))
+	return nil
+}
+
+func (c *pairCollector) Calls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
+
+func newTestStats(collectors ...Collector) *Stats {
+	s := NewStats()
+	s.Collectors = collectors
+	return s
+}
+
+// TestStatsSnapshot reads snapshots while Run collects, it is meant to be
+// run with -race.
+func TestStatsSnapshot(t *testing.T) {
+	fast := &pairCollector{name: "fast", interval: time.Millisecond}
+	other := &pairCollector{name: "other", interval: 3 * time.Millisecond}
+	s := newTestStats(fast, other)
+	go s.Run()
+
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for fast.Calls() < 20 {
+			sample := s.Snapshot()
+			for _, name := range []string{"fast", "other"} {
+				p, ok := sample.State(name).(*pairState)
+				if !ok {
+					continue
+				}
+				// only whole collections are published
+				if a, b := p.A.Newest(-1), p.B.Newest(-1); len(a) != len(b) || p.A.Last() != p.B.Last() {
+					t.Errorf("%s: snapshot of half a collection, %v and %v", name, a, b)
+					return
+				}
+				for _, m := range sample.Metrics() {
+					m.Floats(-1)
+					m.Bounds(5)
+				}
+			}
+		}
+	}()
+
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case <-s.Updated:
+			continue
+		case <-done:
+		case <-timeout:
+			t.Fatal("timed out")
+		}
+		break
+	}
+}
+
+func TestStatsRunUpdated(t *testing.T) {
+	a := &pairCollector{name: "a", interval: 50 * time.Millisecond}
+	b := &pairCollector{name: "b", interval: 50 * time.Millisecond}
+	s := newTestStats(a, b)
+	go s.Run()
+
+	// collectors due at the same time are announced together
+	for event := 1; event <= 3; event++ {
+		select {
+		case <-s.Updated:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out")
+		}
+		if a.Calls() != event || b.Calls() != event {
+			t.Fatalf("update %d after %d and %d collections", event, a.Calls(), b.Calls())
+		}
+	}
+}
+
+func TestStatsSchedule(t *testing.T) {
+	slow := &pairCollector{name: "slow", interval: time.Hour}
+	off := &pairCollector{name: "off", interval: time.Millisecond}
+	s := newTestStats(slow, off)
+	s.Schedule(map[string]time.Duration{"slow": time.Millisecond}, map[string]bool{"off": true})
+	go s.Run()
+
+	wait := func() {
+		t.Helper()
+		select {
+		case <-s.Updated:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out")
+		}
+	}
+	for i := 0; i < 3; i++ {
+		wait()
+	}
+	if slow.Calls() < 3 || off.Calls() != 0 {
+		t.Fatalf("slow collected %d times, off %d times", slow.Calls(), off.Calls())
+	}
+
+	// rescheduling while running
+	s.Schedule(map[string]time.Duration{"off": time.Millisecond}, map[string]bool{"slow": true})
+	for off.Calls() == 0 {
+		wait()
+	}
+	calls := slow.Calls()
+	for i := 0; i < 3; i++ {
+		wait()
+	}
+	if slow.Calls() != calls {
+		t.Errorf("slow collected %d times after disabling it", slow.Calls()-calls)
+	}
+	if s.Snapshot().State("off") == nil {
+		t.Error("no state of the enabled collector")
+	}
+}
+
+// intervalCollector records the intervals it is told about.
+type intervalCollector struct {
+	pairCollector
+	intervals chan time.Duration
+}
+
+func (c *intervalCollector) SetInterval(interval time.Duration) { c.intervals <- interval }
+
+func TestStatsSetInterval(t *testing.T) {
+	c := &intervalCollector{pairCollector: pairCollector{name: "rates", interval: time.Hour}, intervals: make(chan time.Duration, 10)}
+	s := newTestStats(c)
+	go s.Run()
+	go func() {
+		for range s.Updated {
+		}
+	}()
+
+	next := func(want time.Duration) {
+		t.Helper()
+		select {
+		case got := <-c.intervals:
+			if got != want {
+				t.Errorf("interval %v, want %v", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out")
+		}
+	}
+	next(time.Hour)
+	s.Schedule(map[string]time.Duration{"rates": 2 * time.Hour}, nil)
+	next(2 * time.Hour)
+}
+
+func TestMetricNames(t *testing.T) {
+	useRoot(t, fixtureRoot)
+	s := &Sample{}
+	// twice, for the rates and the series that only start with them
+	for i := 0; i < 2; i++ {
+		for _, c := range newCollectors() {
+			c.Collect(s)
+		}
+	}
+	names := 0
+	for _, m := range s.Metrics() {
+		names++
+		if !IsMetricName(m.Name()) {
+			t.Errorf("%s is missing from MetricNames", m.Name())
+		}
+	}
+	if names < 20 {
+		t.Errorf("only %d series collected from the fixture", names)
+	}
+	for _, name := range []string{"cpuu", "net.wlan0", "disk.sda.reads", "battery"} {
+		if IsMetricName(name) {
+			t.Errorf("%s is taken for a metric name", name)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package graph
+
+import (
+	"image/color"
+	"time"
+
+	"github.com/lian/gonky/widgets"
+	"github.com/llgcode/draw2d/draw2dimg"
+)
+
+// Series draws a metric with Line, or with Buckets when span is set.
+func Series(gc *draw2dimg.GraphicContext, metric widgets.Metric, min, max float64, width, padding int, span time.Duration, yOffset, height float64) {
+	if span > 0 {
+		Buckets(gc, metric.Buckets(span), metric.LastAt(), span, min, max, width, yOffset, height)
+		return
+	}
+	Line(gc, metric, min, max, width, padding, yOffset, height)
+}
+
+// Bounds returns the smallest and largest value Series draws of a metric
+// with the same arguments, so a graph is scaled to what it shows and not
+// to a spike that scrolled out of view long ago.
+func Bounds(metric widgets.Metric, width, padding int, span time.Duration) (min, max float64) {
+	if span <= 0 {
+		if padding < 1 {
+			padding = 1
+		}
+		return metric.Bounds(width / padding)
+	}
+	for i, b := range metric.Buckets(span) {
+		if i == 0 || b.Min < min {
+			min = b.Min
+		}
+		if i == 0 || b.Max > max {
+			max = b.Max
+		}
+	}
+	return min, max
+}
+
+// Line strokes the newest values of a metric as a step graph. Every value
+// is padding pixels wide and as many values are drawn as fit into width.
+// Values are scaled between min and max into a graph of the given height
+// whose top is at yOffset.
+func Line(gc *draw2dimg.GraphicContext, metric widgets.Metric, min, max float64, width, padding int, yOffset, height float64) {
+	if padding < 1 {
+		padding = 1
+	}
+
+	for i, value := range metric.Floats(width / padding) {
+		y := Scale(value, min, max, height) + yOffset
+		if i == 0 {
+			gc.MoveTo(float64(i*padding), y)
+		} else {
+			gc.LineTo(float64(i*padding), y)
+		}
+		gc.LineTo(float64(i*padding)+float64(padding), y)
+	}
+	gc.Stroke()
+}
+
+// Scale returns the distance of value from the top of a graph of the
+// given height ranging from min at the bottom to max at the top.
+func Scale(value, min, max, height float64) float64 {
+	if max <= min {
+		return height
+	}
+	if value < min {
+		value = min
+	}
+	if value > max {
+		value = max
+	}
+	return height - float64(int(((value-min)/(max-min))*height))
+}
+
+// Buckets strokes the averages of downsampled buckets as a step graph
+// covering the span before end over width pixels. Values are scaled like
+// in Line.
+func Buckets(gc *draw2dimg.GraphicContext, buckets []widgets.Bucket, end time.Time, span time.Duration, min, max float64, width int, yOffset, height float64) {
+	if len(buckets) == 0 || span <= 0 {
+		return
+	}
+
+	start := end.Add(-span)
+	x := func(t time.Time) float64 {
+		return float64(t.Sub(start)) / float64(span) * float64(width)
+	}
+
+	for i, b := range buckets {
+		y := Scale(b.Avg, min, max, height) + yOffset
+		x1 := x(b.Start)
+		if x1 < 0 {
+			x1 = 0
+		}
+		x2 := float64(width)
+		if i+1 < len(buckets) {
+			x2 = x(buckets[i+1].Start)
+		}
+		if i == 0 {
+			gc.MoveTo(x1, y)
+		} else {
+			gc.LineTo(x1, y)
+		}
+		gc.LineTo(x2, y)
+	}
+	gc.Stroke()
+}
+
+// Colors is the palette used for graphs drawing several series at once.
+var Colors = []color.RGBA{
+	{0x66, 0x66, 0x66, 0xff},
+	{0x88, 0x55, 0x55, 0xff},
+	{0x55, 0x88, 0x55, 0xff},
+	{0x55, 0x55, 0x88, 0xff},
+	{0x88, 0x88, 0x55, 0xff},
+	{0x55, 0x88, 0x88, 0xff},
+	{0x88, 0x55, 0x88, 0xff},
+	{0x99, 0x99, 0x99, 0xff},
+}
+
+// Stacked fills the newest values of the metrics as areas stacked on top
+// of each other, the first metric at the bottom, in the Colors palette.
+// The values are scaled from 0 to max, or to the highest stack when max
+// is 0. Series of different length are aligned at their newest value.
+func Stacked(gc *draw2dimg.GraphicContext, metrics []widgets.Metric, max float64, width, padding int, yOffset, height float64) {
+	if padding < 1 {
+		padding = 1
+	}
+
+	values := make([][]float64, len(metrics))
+	points := 0
+	for i, m := range metrics {
+		values[i] = m.Floats(width / padding)
+		if len(values[i]) > points {
+			points = len(values[i])
+		}
+	}
+	value := func(layer, i int) float64 {
+		j := i - (points - len(values[layer]))
+		if j < 0 {
+			return 0
+		}
+		return values[layer][j]
+	}
+
+	bottoms := make([]float64, points)
+	tops := make([]float64, points)
+	if max <= 0 {
+		for i := 0; i < points; i++ {
+			sum := 0.0
+			for layer := range values {
+				sum += value(layer, i)
+			}
+			if sum > max {
+				max = sum
+			}
+		}
+	}
+
+	for layer := range values {
+		for i := 0; i < points; i++ {
+			tops[i] = bottoms[i] + value(layer, i)
+		}
+
+		gc.SetFillColor(Colors[layer%len(Colors)])
+		for i := 0; i < points; i++ {
+			x := float64(i * padding)
+			y := Scale(tops[i], 0, max, height) + yOffset
+			if i == 0 {
+				gc.MoveTo(x, y)
+			} else {
+				gc.LineTo(x, y)
+			}
+			gc.LineTo(x+float64(padding), y)
+		}
+		for i := points - 1; i >= 0; i-- {
+			x := float64(i * padding)
+			y := Scale(bottoms[i], 0, max, height) + yOffset
+			gc.LineTo(x+float64(padding), y)
+			gc.LineTo(x, y)
+		}
+		gc.Close()
+		gc.Fill()
+
+		copy(bottoms, tops)
+	}
+}
+
+// Marks draws a vertical line over the full height for every value of a
+// metric that is above zero, like throttle events. Positions match Line,
+// or Buckets when span is set.
+func Marks(gc *draw2dimg.GraphicContext, metric widgets.Metric, width, padding int, span time.Duration, yOffset, height float64) {
+	if padding < 1 {
+		padding = 1
+	}
+
+	mark := func(x float64) {
+		gc.MoveTo(x, yOffset)
+		gc.LineTo(x, yOffset+height)
+	}
+
+	if span > 0 {
+		start := metric.LastAt().Add(-span)
+		for _, b := range metric.Buckets(span) {
+			if b.Max > 0 {
+				mark(float64(b.Start.Sub(start)) / float64(span) * float64(width))
+			}
+		}
+	} else {
+		for i, value := range metric.Floats(width / padding) {
+			if value > 0 {
+				mark(float64(i*padding) + float64(padding)/2)
+			}
+		}
+	}
+	gc.Stroke()
+}
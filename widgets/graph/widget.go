@@ -0,0 +1,141 @@
+package graph
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/lian/gonky/shader"
+	"github.com/lian/gonky/texture"
+	"github.com/lian/gonky/widgets"
+	"github.com/llgcode/draw2d/draw2dimg"
+	"github.com/llgcode/draw2d/draw2dkit"
+
+	font "github.com/lian/gonky/font/terminus"
+)
+
+// Graph is a widget drawing the series named by Metrics, one row each.
+// Metrics may contain patterns like "disk.*.read", which match every
+// series of that kind.
+type Graph struct {
+	Texture *texture.Texture
+	Stats   *widgets.Stats
+
+	Metrics      []string
+	GraphPadding int
+	RowHeight    float64
+	RowSpacing   float64
+	// Range switches from the raw values to the downsampled history.
+	Range time.Duration
+	// Format formats the newest value shown next to each row.
+	Format func(value float64) string
+
+	// Stacked draws all metrics in a single row as stacked areas, scaled
+	// up to the newest value of the MaxMetric series if one is named.
+	Stacked   bool
+	MaxMetric string
+
+	Background color.RGBA
+	Foreground color.RGBA
+}
+
+func New(program *shader.Program, stats *widgets.Stats, x, y, width, height float64, metrics ...string) *Graph {
+	g := &Graph{
+		Texture:      &texture.Texture{X: x, Y: y, Width: width, Height: height},
+		Stats:        stats,
+		Metrics:      metrics,
+		GraphPadding: 8,
+		RowHeight:    40,
+		RowSpacing:   20,
+		Format:       func(value float64) string { return fmt.Sprintf("%.1f", value) },
+		Background:   color.RGBA{0x33, 0x33, 0x33, 0xff},
+		Foreground:   color.RGBA{0x66, 0x66, 0x66, 0xff},
+	}
+	g.Texture.Setup(program)
+	return g
+}
+
+func (g *Graph) Render() {
+	data := image.NewRGBA(image.Rect(0, 0, int(g.Texture.Width), int(g.Texture.Height)))
+	gc := draw2dimg.NewGraphicContext(data)
+
+	gc.SetFillColor(g.Background)
+	draw2dkit.Rectangle(gc, 0, 0, g.Texture.Width, g.Texture.Height)
+	gc.Fill()
+
+	gc.SetStrokeColor(g.Foreground)
+	gc.SetLineWidth(1.0)
+
+	stats := g.Stats.Snapshot()
+	if g.Stacked {
+		g.renderStacked(gc, data, stats)
+		g.Texture.Write(&data.Pix)
+		return
+	}
+
+	yOffset := 0.0
+	for _, metric := range g.match(stats) {
+		text := g.Format(metric.Latest())
+		width := int(g.Texture.Width) - (font.Width * (len(text) + 1))
+
+		// rates and percentages start at 0, anything that can go below
+		// that is scaled to its own range
+		min, max := Bounds(metric, width, g.GraphPadding, g.Range)
+		if min > 0 {
+			min = 0
+		}
+		Series(gc, metric, min, max, width, g.GraphPadding, g.Range, yOffset, g.RowHeight)
+
+		x := int(g.Texture.Width) - (font.Width * len(text))
+		y := int(yOffset + ((g.RowHeight - font.Height) / 2))
+		font.DrawString(data, x, y, text, g.Foreground)
+
+		yOffset += g.RowHeight + g.RowSpacing
+	}
+
+	g.Texture.Write(&data.Pix)
+}
+
+func (g *Graph) renderStacked(gc *draw2dimg.GraphicContext, data *image.RGBA, stats *widgets.Sample) {
+	metrics := g.match(stats)
+	if len(metrics) == 0 {
+		return
+	}
+
+	max := 0.0
+	if m := stats.Metric(g.MaxMetric); m != nil {
+		max = m.Latest()
+	}
+
+	total := 0.0
+	for _, m := range metrics {
+		total += m.Latest()
+	}
+	text := g.Format(total)
+	width := int(g.Texture.Width) - (font.Width * (len(text) + 1))
+	Stacked(gc, metrics, max, width, g.GraphPadding, 0, g.RowHeight)
+
+	x := int(g.Texture.Width) - (font.Width * len(text))
+	y := int((g.RowHeight - font.Height) / 2)
+	font.DrawString(data, x, y, text, g.Foreground)
+}
+
+// match returns the metrics of the sample matching g.Metrics, in the
+// order of the patterns and by name within a pattern.
+func (g *Graph) match(stats *widgets.Sample) []widgets.Metric {
+	all := stats.Metrics()
+	sort.Slice(all, func(i, j int) bool { return all[i].Name() < all[j].Name() })
+
+	var metrics []widgets.Metric
+	for _, pattern := range g.Metrics {
+		for _, m := range all {
+			if ok, _ := path.Match(pattern, m.Name()); ok {
+				metrics = append(metrics, m)
+			}
+		}
+	}
+	return metrics
+}
@@ -0,0 +1,372 @@
+package widgets
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// HistoryInterval is how often Stats appends new values to its History.
+var HistoryInterval time.Duration = time.Minute
+
+const historyMagic = "gonkyhs1"
+
+// historyMaxPayload bounds a record so a corrupt length can not make the
+// loader allocate huge buffers.
+const historyMaxPayload = 1 + 1 + 255 + 8 + 3*8 + 4
+
+// DefaultHistoryPath returns $XDG_STATE_HOME/gonky/history, falling back
+// to ~/.local/state when XDG_STATE_HOME is not set.
+func DefaultHistoryPath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = os.TempDir()
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "gonky", "history")
+}
+
+type historyRecord struct {
+	name   string
+	level  int
+	bucket Bucket
+}
+
+type historyMark struct {
+	name  string
+	level int
+}
+
+type historyBucket struct {
+	name  string
+	level int
+	start int64
+}
+
+// History persists series in an append-only file. Each record holds one
+// raw value or tier bucket and is checksummed, so a file that was cut off
+// or damaged is read up to the last good record and the rest is dropped.
+// The file is rewritten with only the retained records when it is opened
+// and whenever it has grown to twice that size.
+type History struct {
+	path string
+
+	mu          sync.Mutex
+	file        *os.File
+	size        int64
+	compactSize int64
+	pending     map[string][]historyRecord
+	marks       map[historyMark]time.Time
+}
+
+// OpenHistory loads the history file at path, creating it and its
+// directory if needed. The loaded records are restored into series as
+// they show up in Stats.
+func OpenHistory(path string) (*History, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	h := &History{
+		path:    path,
+		pending: map[string][]historyRecord{},
+		marks:   map[historyMark]time.Time{},
+	}
+
+	records, err := readHistory(path)
+	if err != nil {
+		log.Printf("history %s: %v, dropping the rest of the file", path, err)
+	}
+	for _, r := range retainHistory(records, time.Now()) {
+		h.pending[r.name] = append(h.pending[r.name], r)
+	}
+
+	if err := h.rewrite(nil); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// restore feeds the loaded records of every given series back into it.
+func (h *History) restore(metrics []Metric) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.pending) == 0 {
+		return
+	}
+	for _, m := range metrics {
+		records, ok := h.pending[m.Name()]
+		if !ok {
+			continue
+		}
+		for _, r := range records {
+			m.Restore(r.level, r.bucket)
+			// a bucket that was merged into the one being filled has to be
+			// written again once it is complete
+			if current, ok := m.Partial(r.level); ok && !r.bucket.Start.Before(current.Start) {
+				continue
+			}
+			h.mark(r)
+		}
+		delete(h.pending, m.Name())
+	}
+}
+
+// Flush appends everything that was added to the series since the
+// previous flush.
+func (h *History) Flush(metrics []Metric) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.file == nil {
+		return errors.New("history is closed")
+	}
+
+	if h.size > 2*h.compactSize && h.size > 1<<20 {
+		return h.rewrite(metrics)
+	}
+
+	var buf bytes.Buffer
+	for _, r := range h.newRecords(metrics) {
+		writeHistoryRecord(&buf, r)
+		h.mark(r)
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	n, err := h.file.Write(buf.Bytes())
+	h.size += int64(n)
+	return err
+}
+
+// Close appends what was added since the previous flush along with the
+// tier buckets that are still being filled, so they are not lost on a
+// restart, and closes the file.
+func (h *History) Close(metrics []Metric) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.file == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, r := range h.newRecords(metrics) {
+		writeHistoryRecord(&buf, r)
+	}
+	for _, m := range metrics {
+		for level := 1; level <= len(DefaultTiers); level++ {
+			if b, ok := m.Partial(level); ok {
+				writeHistoryRecord(&buf, historyRecord{name: m.Name(), level: level, bucket: b})
+			}
+		}
+	}
+	_, err := h.file.Write(buf.Bytes())
+	if cerr := h.file.Close(); err == nil {
+		err = cerr
+	}
+	h.file = nil
+	return err
+}
+
+func (h *History) mark(r historyRecord) {
+	key := historyMark{r.name, r.level}
+	if r.bucket.Start.After(h.marks[key]) {
+		h.marks[key] = r.bucket.Start
+	}
+}
+
+func (h *History) newRecords(metrics []Metric) []historyRecord {
+	var records []historyRecord
+	for _, m := range metrics {
+		for level := 0; level <= len(DefaultTiers); level++ {
+			since := h.marks[historyMark{m.Name(), level}]
+			for _, b := range m.Since(level, since) {
+				records = append(records, historyRecord{name: m.Name(), level: level, bucket: b})
+			}
+		}
+	}
+	return records
+}
+
+// rewrite replaces the file with the records that have not been restored
+// yet and the complete contents of metrics.
+func (h *History) rewrite(metrics []Metric) error {
+	var buf bytes.Buffer
+	buf.WriteString(historyMagic)
+	for _, records := range h.pending {
+		for _, r := range records {
+			writeHistoryRecord(&buf, r)
+		}
+	}
+	for _, m := range metrics {
+		for level := 0; level <= len(DefaultTiers); level++ {
+			for _, b := range m.Since(level, time.Time{}) {
+				r := historyRecord{name: m.Name(), level: level, bucket: b}
+				writeHistoryRecord(&buf, r)
+				h.mark(r)
+			}
+		}
+	}
+
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		return err
+	}
+
+	if h.file != nil {
+		h.file.Close()
+	}
+	file, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		h.file = nil
+		return err
+	}
+	h.file = file
+	h.size = int64(buf.Len())
+	h.compactSize = h.size
+	return nil
+}
+
+// retainHistory drops tier buckets that are past their retention and all
+// but the newest SeriesCapacity raw values of every series. A tier bucket
+// that was written while it was still being filled is superseded by a
+// later record of the same bucket.
+func retainHistory(records []historyRecord, now time.Time) []historyRecord {
+	raw := map[string]int{}
+	newest := map[historyBucket]int{}
+	for i, r := range records {
+		if r.level == 0 {
+			raw[r.name]++
+		} else {
+			newest[historyBucket{r.name, r.level, r.bucket.Start.UnixNano()}] = i
+		}
+	}
+
+	var retained []historyRecord
+	for i, r := range records {
+		if r.level == 0 {
+			if raw[r.name] > SeriesCapacity {
+				raw[r.name]--
+				continue
+			}
+		} else if newest[historyBucket{r.name, r.level, r.bucket.Start.UnixNano()}] != i {
+			continue
+		} else if r.level > len(DefaultTiers) {
+			continue
+		} else if r.bucket.Start.Before(now.Add(-DefaultTiers[r.level-1].Retention)) {
+			continue
+		}
+		retained = append(retained, r)
+	}
+	return retained
+}
+
+// readHistory returns the records of the file at path. A missing file is
+// not an error. When the file is damaged the records up to the damage are
+// returned together with the error.
+func readHistory(path string) ([]historyRecord, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+
+	magic := make([]byte, len(historyMagic))
+	if _, err := io.ReadFull(r, magic); err == io.EOF {
+		return nil, nil
+	} else if err != nil || string(magic) != historyMagic {
+		return nil, errors.New("not a history file")
+	}
+
+	var records []historyRecord
+	for {
+		record, err := readHistoryRecord(r)
+		if err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return records, fmt.Errorf("record %d: %v", len(records), err)
+		}
+		records = append(records, record)
+	}
+}
+
+func readHistoryRecord(r io.Reader) (historyRecord, error) {
+	var record historyRecord
+
+	var size uint32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return record, errors.New("truncated")
+		}
+		return record, err
+	}
+	if size < 2 || size > historyMaxPayload {
+		return record, fmt.Errorf("bad length %d", size)
+	}
+
+	buf := make([]byte, size+4)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return record, errors.New("truncated")
+	}
+	payload := buf[:size]
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(buf[size:]) {
+		return record, errors.New("checksum mismatch")
+	}
+
+	record.level = int(payload[0])
+	nameLen := int(payload[1])
+	if len(payload) != 2+nameLen+8+3*8+4 {
+		return record, errors.New("bad record size")
+	}
+	record.name = string(payload[2 : 2+nameLen])
+
+	p := payload[2+nameLen:]
+	record.bucket.Start = time.Unix(0, int64(binary.LittleEndian.Uint64(p[0:])))
+	record.bucket.Avg = math.Float64frombits(binary.LittleEndian.Uint64(p[8:]))
+	record.bucket.Min = math.Float64frombits(binary.LittleEndian.Uint64(p[16:]))
+	record.bucket.Max = math.Float64frombits(binary.LittleEndian.Uint64(p[24:]))
+	record.bucket.Count = int(binary.LittleEndian.Uint32(p[32:]))
+	return record, nil
+}
+
+func writeHistoryRecord(w *bytes.Buffer, r historyRecord) {
+	name := r.name
+	if len(name) > 255 {
+		name = name[:255]
+	}
+
+	payload := make([]byte, 0, 2+len(name)+8+3*8+4)
+	payload = append(payload, byte(r.level), byte(len(name)))
+	payload = append(payload, name...)
+	payload = binary.LittleEndian.AppendUint64(payload, uint64(r.bucket.Start.UnixNano()))
+	payload = binary.LittleEndian.AppendUint64(payload, math.Float64bits(r.bucket.Avg))
+	payload = binary.LittleEndian.AppendUint64(payload, math.Float64bits(r.bucket.Min))
+	payload = binary.LittleEndian.AppendUint64(payload, math.Float64bits(r.bucket.Max))
+	payload = binary.LittleEndian.AppendUint32(payload, uint32(r.bucket.Count))
+
+	binary.Write(w, binary.LittleEndian, uint32(len(payload)))
+	w.Write(payload)
+	binary.Write(w, binary.LittleEndian, crc32.ChecksumIEEE(payload))
+}
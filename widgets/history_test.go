@@ -0,0 +1,306 @@
+package widgets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pushEvery pushes n values, one every step from start on, so the tiers
+// of s fill up as well.
+func pushEvery(s *Series[float64], start time.Time, step time.Duration, n int) {
+	for i := 0; i < n; i++ {
+		s.PushAt(start.Add(time.Duration(i)*step), float64(i%100))
+	}
+}
+
+func equalBuckets(t *testing.T, what string, got, want []Bucket) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: %d buckets, want %d", what, len(got), len(want))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if !g.Start.Equal(w.Start) || g.Avg != w.Avg || g.Min != w.Min || g.Max != w.Max || g.Count != w.Count {
+			t.Fatalf("%s: bucket %d is %+v, want %+v", what, i, g, w)
+		}
+	}
+}
+
+// equalSeries compares the raw values and every tier of two series,
+// including the buckets that are still being filled.
+func equalSeries(t *testing.T, got, want *Series[float64]) {
+	t.Helper()
+	equalBuckets(t, want.Name(), got.Since(0, time.Time{}), want.Since(0, time.Time{}))
+	for i := range want.Tiers() {
+		equalBuckets(t, want.Name(), got.Tiers()[i].Buckets(time.Time{}), want.Tiers()[i].Buckets(time.Time{}))
+	}
+}
+
+func TestHistoryRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gonky", "history")
+	h, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cpu := NewSeries[float64]("cpu", SeriesCapacity)
+	load := NewSeries[float64]("load.1", SeriesCapacity)
+	start := time.Now().Add(-2 * time.Hour)
+	pushEvery(cpu, start, 5*time.Second, 2*60*12)
+	pushEvery(load, start, time.Minute, 2*60)
+	metrics := []Metric{cpu, load}
+
+	if err := h.Flush(metrics); err != nil {
+		t.Fatal(err)
+	}
+	// a second flush only appends what is new
+	cpu.PushAt(time.Now(), 42)
+	if err := h.Flush(metrics); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close(metrics); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err = OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(nil)
+	restoredCPU := NewSeries[float64]("cpu", SeriesCapacity)
+	restoredLoad := NewSeries[float64]("load.1", SeriesCapacity)
+	h.restore([]Metric{restoredCPU, restoredLoad})
+
+	equalSeries(t, restoredCPU, cpu)
+	equalSeries(t, restoredLoad, load)
+	if restoredCPU.Last() != 42 {
+		t.Errorf("restored last value is %v, want 42", restoredCPU.Last())
+	}
+}
+
+func TestHistoryRewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	h, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cpu := NewSeries[float64]("cpu", SeriesCapacity)
+	start := time.Now().Add(-3 * time.Hour)
+	pushEvery(cpu, start, 10*time.Second, 3*60*6)
+	// flushing value by value leaves the file much larger than the data
+	for i := 0; i < 10; i++ {
+		cpu.PushAt(time.Now().Add(time.Duration(i-10)*time.Second), float64(i))
+		if err := h.Flush([]Metric{cpu}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before, _ := os.Stat(path)
+
+	h.mu.Lock()
+	err = h.rewrite([]Metric{cpu})
+	h.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.Stat(path)
+	if after.Size() > before.Size() {
+		t.Errorf("rewrite grew the file from %d to %d bytes", before.Size(), after.Size())
+	}
+
+	records, err := readHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 0
+	for level := 0; level <= len(DefaultTiers); level++ {
+		want += len(cpu.Since(level, time.Time{}))
+	}
+	if len(records) != want {
+		t.Errorf("rewritten file has %d records, want %d", len(records), want)
+	}
+
+	// appending after the rewrite goes to the new file
+	cpu.PushAt(time.Now(), 99)
+	if err := h.Flush([]Metric{cpu}); err != nil {
+		t.Fatal(err)
+	}
+	h.Close([]Metric{cpu})
+
+	h, err = OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(nil)
+	restored := NewSeries[float64]("cpu", SeriesCapacity)
+	h.restore([]Metric{restored})
+	equalSeries(t, restored, cpu)
+}
+
+func TestHistoryPartialBuckets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	h, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Truncate(15 * time.Minute).Add(-time.Hour)
+	cpu := NewSeries[float64]("cpu", SeriesCapacity)
+	pushEvery(cpu, start, 10*time.Second, 3)
+	if err := h.Close([]Metric{cpu}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a value collected before the history is restored shares the buckets
+	// that were still being filled on close
+	h, err = OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := NewSeries[float64]("cpu", SeriesCapacity)
+	live.PushAt(start.Add(30*time.Second), 3)
+	h.restore([]Metric{live})
+	for i, tier := range live.Tiers() {
+		if b, ok := live.Partial(i + 1); !ok || !b.Start.Equal(start) || b.Count != 4 || len(tier.Completed(time.Time{})) != 0 {
+			t.Fatalf("tier %d is filling %+v with %d completed, want 4 values from %v", i, b, len(tier.Completed(time.Time{})), start)
+		}
+	}
+
+	// once complete the merged buckets are written again
+	live.PushAt(start.Add(2*time.Minute), 4)
+	live.PushAt(start.Add(16*time.Minute), 5)
+	if err := h.Flush([]Metric{live}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close([]Metric{live}); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err = OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(nil)
+	restored := NewSeries[float64]("cpu", SeriesCapacity)
+	h.restore([]Metric{restored})
+	equalSeries(t, restored, live)
+}
+
+// historyFile returns a history file with n raw records of "cpu" and the
+// offset of every record.
+func historyFile(n int) ([]byte, []int) {
+	var buf bytes.Buffer
+	buf.WriteString(historyMagic)
+	var offsets []int
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < n; i++ {
+		offsets = append(offsets, buf.Len())
+		writeHistoryRecord(&buf, historyRecord{
+			name:   "cpu",
+			bucket: Bucket{Start: start.Add(time.Duration(i) * time.Second), Avg: float64(i), Min: float64(i), Max: float64(i), Count: 1},
+		})
+	}
+	return buf.Bytes(), offsets
+}
+
+func TestReadHistoryDamaged(t *testing.T) {
+	good, offsets := historyFile(3)
+	last := offsets[2]
+
+	tests := []struct {
+		name    string
+		damage  func(b []byte) []byte
+		records int
+		err     string
+	}{
+		{"intact", func(b []byte) []byte { return b }, 3, ""},
+		{"empty", func(b []byte) []byte { return nil }, 0, ""},
+		{"only magic", func(b []byte) []byte { return b[:len(historyMagic)] }, 0, ""},
+		{"cut in the length", func(b []byte) []byte { return b[:last+2] }, 2, "truncated"},
+		{"cut in the payload", func(b []byte) []byte { return b[:len(b)-7] }, 2, "truncated"},
+		{"bad checksum", func(b []byte) []byte {
+			b[len(b)-1] ^= 0xff
+			return b
+		}, 2, "checksum mismatch"},
+		{"bad payload", func(b []byte) []byte {
+			b[last+4+2] ^= 0xff
+			return b
+		}, 2, "checksum mismatch"},
+		{"bogus length", func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[last:], historyMaxPayload+1)
+			return b
+		}, 2, "bad length"},
+		{"short length", func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[last:], 1)
+			return b
+		}, 2, "bad length"},
+		{"bad magic", func(b []byte) []byte {
+			b[0] = 'x'
+			return b
+		}, 0, "not a history file"},
+		{"short magic", func(b []byte) []byte { return b[:3] }, 0, "not a history file"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "history")
+			damaged := test.damage(append([]byte(nil), good...))
+			if err := os.WriteFile(path, damaged, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			records, err := readHistory(path)
+			if len(records) != test.records {
+				t.Errorf("got %d records, want %d", len(records), test.records)
+			}
+			for i, r := range records {
+				if r.name != "cpu" || r.bucket.Avg != float64(i) {
+					t.Errorf("record %d is %+v", i, r)
+				}
+			}
+			switch {
+			case test.err == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+				t.Errorf("error %v, want one containing %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestReadHistoryMissing(t *testing.T) {
+	records, err := readHistory(filepath.Join(t.TempDir(), "history"))
+	if records != nil || err != nil {
+		t.Errorf("readHistory of a missing file = %v, %v", records, err)
+	}
+}
+
+func TestOpenHistoryDamaged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	good, _ := historyFile(3)
+	if err := os.WriteFile(path, good[:len(good)-7], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(nil)
+
+	// the damaged tail is gone from the rewritten file
+	records, err := readHistory(path)
+	if err != nil || len(records) != 2 {
+		t.Errorf("rewritten file has %d records, error %v, want 2 records", len(records), err)
+	}
+
+	cpu := NewSeries[float64]("cpu", SeriesCapacity)
+	h.restore([]Metric{cpu})
+	if got := cpu.Newest(-1); len(got) != 2 || got[0] != 0 || got[1] != 1 {
+		t.Errorf("restored %v, want [0 1]", got)
+	}
+}
@@ -0,0 +1,76 @@
+package widgets
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// TemperatureSensor is a temp*_input file of a hwmon device.
+type TemperatureSensor struct {
+	// Device is the hwmon directory, e.g. hwmon2.
+	Device string
+	// Chip is the hwmon name, e.g. coretemp, k10temp, nvme or acpitz.
+	Chip string
+	// Label is the tempN_label of the sensor, or tempN when there is none.
+	Label string
+	// Path is the tempN_input file, relative to Root.
+	Path string
+}
+
+// Name identifies the sensor as "chip/label". Identical chips, like two
+// NVMe drives, share their names.
+func (t TemperatureSensor) Name() string {
+	return t.Chip + "/" + t.Label
+}
+
+// Key identifies the sensor as "device/chip/label", which is unique but
+// only stable until the next boot.
+func (t TemperatureSensor) Key() string {
+	return t.Device + "/" + t.Name()
+}
+
+// DiscoverTemperatureSensors returns every /sys/class/hwmon/*/temp*_input.
+// The hwmon index is not stable across boots, so sensors should be looked
+// up by Name rather than by Path.
+func DiscoverTemperatureSensors() []TemperatureSensor {
+	var sensors []TemperatureSensor
+	for _, input := range discoverHwmon("temp") {
+		sensors = append(sensors, TemperatureSensor{Device: input.device, Chip: input.chip, Label: input.label, Path: input.path})
+	}
+	return sensors
+}
+
+type hwmonInput struct {
+	device string
+	chip   string
+	label  string
+	path   string
+}
+
+// discoverHwmon finds the <kind>N_input files of all hwmon devices along
+// with the device name and the label of each input.
+func discoverHwmon(kind string) []hwmonInput {
+	paths, err := Glob("/sys/class/hwmon/*/" + kind + "*_input")
+	if err != nil {
+		return nil
+	}
+
+	var inputs []hwmonInput
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		id := strings.TrimSuffix(filepath.Base(path), "_input")
+
+		chip, err := ReadString(filepath.Join(dir, "name"))
+		if err != nil || chip == "" {
+			chip = filepath.Base(dir)
+		}
+
+		label, err := ReadString(filepath.Join(dir, id+"_label"))
+		if err != nil || label == "" {
+			label = id
+		}
+
+		inputs = append(inputs, hwmonInput{device: filepath.Base(dir), chip: chip, label: label, path: path})
+	}
+	return inputs
+}
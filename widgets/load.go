@@ -0,0 +1,152 @@
+package widgets
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterCollector("load", func() Collector { return &LoadCollector{} })
+}
+
+// Load holds the scheduler statistics.
+type Load struct {
+	// Load1, Load5 and Load15 are the load averages.
+	Load1  *Series[float64]
+	Load5  *Series[float64]
+	Load15 *Series[float64]
+
+	// TasksRunning and TasksTotal count runnable and existing tasks,
+	// threads included.
+	TasksRunning *Series[int]
+	TasksTotal   *Series[int]
+
+	// ContextSwitches and Interrupts are per second.
+	ContextSwitches *Series[float64]
+	Interrupts      *Series[float64]
+}
+
+func newLoad() *Load {
+	return &Load{
+		Load1:           NewSeries[float64]("load.1", SeriesCapacity),
+		Load5:           NewSeries[float64]("load.5", SeriesCapacity),
+		Load15:          NewSeries[float64]("load.15", SeriesCapacity),
+		TasksRunning:    NewSeries[int]("tasks.running", SeriesCapacity),
+		TasksTotal:      NewSeries[int]("tasks.total", SeriesCapacity),
+		ContextSwitches: NewSeries[float64]("ctxt", SeriesCapacity),
+		Interrupts:      NewSeries[float64]("intr", SeriesCapacity),
+	}
+}
+
+func (l *Load) Metrics() []Metric {
+	return []Metric{l.Load1, l.Load5, l.Load15, l.TasksRunning, l.TasksTotal, l.ContextSwitches, l.Interrupts}
+}
+
+func (l *Load) Clone() State {
+	return &Load{
+		Load1:           l.Load1.Clone(),
+		Load5:           l.Load5.Clone(),
+		Load15:          l.Load15.Clone(),
+		TasksRunning:    l.TasksRunning.Clone(),
+		TasksTotal:      l.TasksTotal.Clone(),
+		ContextSwitches: l.ContextSwitches.Clone(),
+		Interrupts:      l.Interrupts.Clone(),
+	}
+}
+
+// Load returns the state of the load collector.
+func (s *Sample) Load() *Load { return stateOf(s, "load", newLoad) }
+
+// LoadCollector reads /proc/loadavg and the context switch and interrupt
+// counters of /proc/stat.
+type LoadCollector struct {
+	ctxt Counter
+	intr Counter
+}
+
+func (c *LoadCollector) Name() string            { return "load" }
+func (c *LoadCollector) Interval() time.Duration { return time.Second * 5 }
+
+func (c *LoadCollector) SetInterval(interval time.Duration) {
+	c.ctxt.MaxGap = rateGap(interval)
+	c.intr.MaxGap = rateGap(interval)
+}
+
+func (c *LoadCollector) Collect(s *Sample) error {
+	l := ensureState(s, "load", newLoad)
+	if err := c.collectLoadavg(l); err != nil {
+		return err
+	}
+	return c.collectStat(l)
+}
+
+func (c *LoadCollector) collectLoadavg(l *Load) error {
+	buf, err := ReadString("/proc/loadavg")
+	if err != nil {
+		return err
+	}
+
+	// 0.52 0.58 0.59 2/1234 56789
+	fields := strings.Fields(buf)
+	if len(fields) < 4 {
+		return fmt.Errorf("unexpected /proc/loadavg format: %q", buf)
+	}
+	tasks := strings.SplitN(fields[3], "/", 2)
+	if len(tasks) != 2 {
+		return fmt.Errorf("unexpected /proc/loadavg format: %q", buf)
+	}
+
+	load1, err1 := strconv.ParseFloat(fields[0], 64)
+	load5, err2 := strconv.ParseFloat(fields[1], 64)
+	load15, err3 := strconv.ParseFloat(fields[2], 64)
+	running, err4 := strconv.Atoi(tasks[0])
+	total, err5 := strconv.Atoi(tasks[1])
+	for _, err := range []error{err1, err2, err3, err4, err5} {
+		if err != nil {
+			return fmt.Errorf("/proc/loadavg: %v", err)
+		}
+	}
+
+	l.Load1.Push(load1)
+	l.Load5.Push(load5)
+	l.Load15.Push(load15)
+	l.TasksRunning.Push(running)
+	l.TasksTotal.Push(total)
+	return nil
+}
+
+func (c *LoadCollector) collectStat(l *Load) error {
+	buf, err := ReadFile("/proc/stat")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+
+	var ctxt, intr uint64
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	// the intr line lists every interrupt and can get long
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "ctxt":
+			ctxt, _ = strconv.ParseUint(fields[1], 10, 64)
+		case "intr":
+			intr, _ = strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	c.ctxt.Push(l.ContextSwitches, now, ctxt)
+	c.intr.Push(l.Interrupts, now, intr)
+	return nil
+}
@@ -0,0 +1,49 @@
+package widgets
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadCollector(t *testing.T) {
+	useRoot(t, fixtureRoot)
+	c := &LoadCollector{}
+	s := &Sample{}
+	if err := c.Collect(s); err != nil {
+		t.Fatal(err)
+	}
+	l := s.Load()
+
+	if l.Load1.Last() != 0.52 || l.Load5.Last() != 0.58 || l.Load15.Last() != 0.59 {
+		t.Errorf("load averages %v %v %v", l.Load1.Last(), l.Load5.Last(), l.Load15.Last())
+	}
+	if l.TasksRunning.Last() != 2 || l.TasksTotal.Last() != 1234 {
+		t.Errorf("tasks %d/%d, want 2/1234", l.TasksRunning.Last(), l.TasksTotal.Last())
+	}
+	if c.ctxt.last != 1990473 || c.intr.last != 114930548 {
+		t.Errorf("ctxt %d, intr %d", c.ctxt.last, c.intr.last)
+	}
+	// the first reading of a counter has no rate yet
+	if l.ContextSwitches.Len() != 0 {
+		t.Errorf("context switch rate pushed after one reading")
+	}
+}
+
+func TestLoadavgMalformed(t *testing.T) {
+	for _, content := range []string{"", "0.52 0.58 0.59", "0.52 0.58 0.59 2-1234 56789", "a b c 1/2 3"} {
+		root := writeFixture(t, map[string]string{"/proc/loadavg": content})
+		useRoot(t, root)
+		c := &LoadCollector{}
+		if err := c.collectLoadavg(newLoad()); err == nil {
+			t.Errorf("no error for /proc/loadavg %q", content)
+		}
+	}
+}
+
+func TestLoadCollectorInterval(t *testing.T) {
+	c := &LoadCollector{}
+	c.SetInterval(2 * time.Minute)
+	if c.ctxt.MaxGap != 6*time.Minute || c.intr.MaxGap != 6*time.Minute {
+		t.Errorf("counters allow gaps of %v and %v, want 6m", c.ctxt.MaxGap, c.intr.MaxGap)
+	}
+}
@@ -0,0 +1,94 @@
+package widgets
+
+import (
+	"fmt"
+	"time"
+
+	psutil_mem "github.com/shirou/gopsutil/mem"
+)
+
+func init() {
+	RegisterCollector("memory", func() Collector { return &MemoryCollector{} })
+}
+
+// MemoryUsage breaks the memory down into what the kernel reports, all in
+// bytes. Used, Buffers and Cached do not overlap and can be stacked,
+// Available estimates what could be allocated without swapping.
+type MemoryUsage struct {
+	// Percent is the used memory in percent.
+	Percent *Series[float64]
+
+	Total     *Series[uint64]
+	Used      *Series[uint64]
+	Buffers   *Series[uint64]
+	Cached    *Series[uint64]
+	Available *Series[uint64]
+	SwapUsed  *Series[uint64]
+	SwapTotal *Series[uint64]
+}
+
+func newMemoryUsage() *MemoryUsage {
+	return &MemoryUsage{
+		Percent:   NewSeries[float64]("memory", SeriesCapacity),
+		Total:     NewSeries[uint64]("memory.total", SeriesCapacity),
+		Used:      NewSeries[uint64]("memory.used", SeriesCapacity),
+		Buffers:   NewSeries[uint64]("memory.buffers", SeriesCapacity),
+		Cached:    NewSeries[uint64]("memory.cached", SeriesCapacity),
+		Available: NewSeries[uint64]("memory.available", SeriesCapacity),
+		SwapUsed:  NewSeries[uint64]("swap.used", SeriesCapacity),
+		SwapTotal: NewSeries[uint64]("swap.total", SeriesCapacity),
+	}
+}
+
+func (m *MemoryUsage) Metrics() []Metric {
+	return []Metric{m.Percent, m.Total, m.Used, m.Buffers, m.Cached, m.Available, m.SwapUsed, m.SwapTotal}
+}
+
+func (m *MemoryUsage) Clone() State {
+	return &MemoryUsage{
+		Percent:   m.Percent.Clone(),
+		Total:     m.Total.Clone(),
+		Used:      m.Used.Clone(),
+		Buffers:   m.Buffers.Clone(),
+		Cached:    m.Cached.Clone(),
+		Available: m.Available.Clone(),
+		SwapUsed:  m.SwapUsed.Clone(),
+		SwapTotal: m.SwapTotal.Clone(),
+	}
+}
+
+// Memory returns the state of the memory collector.
+func (s *Sample) Memory() *MemoryUsage { return stateOf(s, "memory", newMemoryUsage) }
+
+// MemoryCollector reports the used memory in percent along with the
+// MemoryUsage breakdown.
+type MemoryCollector struct{}
+
+func (c *MemoryCollector) Name() string            { return "memory" }
+func (c *MemoryCollector) Interval() time.Duration { return time.Second * 10 }
+
+func (c *MemoryCollector) Collect(s *Sample) error {
+	v, err := psutil_mem.VirtualMemory()
+	if err != nil {
+		return err
+	}
+	// without a total the used percent is NaN, which JSON can not encode
+	if v.Total == 0 {
+		return fmt.Errorf("no MemTotal in /proc/meminfo")
+	}
+	m := ensureState(s, "memory", newMemoryUsage)
+	m.Percent.Push(v.UsedPercent)
+	m.Total.Push(v.Total)
+	m.Used.Push(v.Used)
+	m.Buffers.Push(v.Buffers)
+	m.Cached.Push(v.Cached)
+	m.Available.Push(v.Available)
+
+	swap, err := psutil_mem.SwapMemory()
+	if err != nil {
+		return err
+	}
+	m.SwapUsed.Push(swap.Used)
+	m.SwapTotal.Push(swap.Total)
+	return nil
+}
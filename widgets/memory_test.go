@@ -0,0 +1,43 @@
+package widgets
+
+import "testing"
+
+const meminfo = `MemTotal:       16000000 kB
+MemFree:         4000000 kB
+MemAvailable:   12000000 kB
+Buffers:          500000 kB
+Cached:          6000000 kB
+SwapCached:            0 kB
+Active:          5000000 kB
+Inactive:        4000000 kB
+SwapTotal:       8000000 kB
+SwapFree:        6000000 kB
+Shmem:            300000 kB
+SReclaimable:     200000 kB
+`
+
+func TestMemoryCollector(t *testing.T) {
+	useRoot(t, writeFixture(t, map[string]string{"/proc/meminfo": meminfo, "/proc/vmstat": ""}))
+	s := &Sample{}
+	if err := (&MemoryCollector{}).Collect(s); err != nil {
+		t.Fatal(err)
+	}
+	m := s.Memory()
+	if m.Total.Last() != 16000000*1024 {
+		t.Errorf("total %d, want %d", m.Total.Last(), 16000000*1024)
+	}
+	if want := float64(m.Used.Last()) / float64(m.Total.Last()) * 100; !near(m.Percent.Last(), want) {
+		t.Errorf("used %v%%, want %v%%", m.Percent.Last(), want)
+	}
+}
+
+func TestMemoryCollectorNoTotal(t *testing.T) {
+	useRoot(t, writeFixture(t, map[string]string{"/proc/meminfo": "MemFree: 4000000 kB\n", "/proc/vmstat": ""}))
+	s := &Sample{}
+	if err := (&MemoryCollector{}).Collect(s); err == nil {
+		t.Error("no error without MemTotal")
+	}
+	if s.State("memory") != nil {
+		t.Error("memory state created without MemTotal")
+	}
+}
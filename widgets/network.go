@@ -0,0 +1,302 @@
+package widgets
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	psutil_net "github.com/shirou/gopsutil/net"
+)
+
+func init() {
+	RegisterCollector("network", func() Collector {
+		return &NetworkCollector{last: map[string]*netCounters{}}
+	})
+}
+
+// Interface holds the traffic of a network interface. The series are
+// rates per second, the counters are totals since the interface came up.
+type Interface struct {
+	Name string
+
+	// IPv4 and IPv6 are the addresses in CIDR notation.
+	IPv4 []string
+	IPv6 []string
+	// OperState is the RFC 2863 state from sysfs, like "up", "down" or
+	// "dormant". Carrier reports whether a link is detected.
+	OperState string
+	Carrier   bool
+
+	// Wireless is set for interfaces listed in /proc/net/wireless.
+	// LinkQuality and SignalLevel are only meaningful for those, the
+	// signal level in dBm.
+	Wireless    bool
+	LinkQuality *Series[float64]
+	SignalLevel *Series[float64]
+
+	BytesRecv   uint64
+	BytesSent   uint64
+	PacketsRecv uint64
+	PacketsSent uint64
+	ErrorsRecv  uint64
+	ErrorsSent  uint64
+	DropsRecv   uint64
+	DropsSent   uint64
+
+	RateRecv       *Series[float64]
+	RateSent       *Series[float64]
+	PacketRateRecv *Series[float64]
+	PacketRateSent *Series[float64]
+	ErrorRateRecv  *Series[float64]
+	ErrorRateSent  *Series[float64]
+	DropRateRecv   *Series[float64]
+	DropRateSent   *Series[float64]
+}
+
+func newInterface(name string) *Interface {
+	prefix := "net." + name + "."
+	return &Interface{
+		Name:           name,
+		RateRecv:       NewSeries[float64](prefix+"rx", SeriesCapacity),
+		RateSent:       NewSeries[float64](prefix+"tx", SeriesCapacity),
+		PacketRateRecv: NewSeries[float64](prefix+"rx_packets", SeriesCapacity),
+		PacketRateSent: NewSeries[float64](prefix+"tx_packets", SeriesCapacity),
+		ErrorRateRecv:  NewSeries[float64](prefix+"rx_errors", SeriesCapacity),
+		ErrorRateSent:  NewSeries[float64](prefix+"tx_errors", SeriesCapacity),
+		DropRateRecv:   NewSeries[float64](prefix+"rx_drops", SeriesCapacity),
+		DropRateSent:   NewSeries[float64](prefix+"tx_drops", SeriesCapacity),
+		LinkQuality:    NewSeries[float64](prefix+"quality", SeriesCapacity),
+		SignalLevel:    NewSeries[float64](prefix+"signal", SeriesCapacity),
+	}
+}
+
+func (i *Interface) rates() [8]*Series[float64] {
+	return [...]*Series[float64]{
+		i.RateRecv, i.RateSent,
+		i.PacketRateRecv, i.PacketRateSent,
+		i.ErrorRateRecv, i.ErrorRateSent,
+		i.DropRateRecv, i.DropRateSent,
+	}
+}
+
+func (i *Interface) metrics() []Metric {
+	var metrics []Metric
+	for _, series := range i.rates() {
+		metrics = append(metrics, series)
+	}
+	return append(metrics, i.LinkQuality, i.SignalLevel)
+}
+
+func (i *Interface) clone() *Interface {
+	c := *i
+	c.RateRecv = i.RateRecv.Clone()
+	c.RateSent = i.RateSent.Clone()
+	c.PacketRateRecv = i.PacketRateRecv.Clone()
+	c.PacketRateSent = i.PacketRateSent.Clone()
+	c.ErrorRateRecv = i.ErrorRateRecv.Clone()
+	c.ErrorRateSent = i.ErrorRateSent.Clone()
+	c.DropRateRecv = i.DropRateRecv.Clone()
+	c.DropRateSent = i.DropRateSent.Clone()
+	c.LinkQuality = i.LinkQuality.Clone()
+	c.SignalLevel = i.SignalLevel.Clone()
+	c.IPv4 = append([]string(nil), i.IPv4...)
+	c.IPv6 = append([]string(nil), i.IPv6...)
+	return &c
+}
+
+// Interfaces is the state of the NetworkCollector, keyed by interface
+// name.
+type Interfaces map[string]*Interface
+
+func (i Interfaces) Metrics() []Metric {
+	var metrics []Metric
+	for _, iface := range i {
+		metrics = append(metrics, iface.metrics()...)
+	}
+	return metrics
+}
+
+func (i Interfaces) Clone() State {
+	c := make(Interfaces, len(i))
+	for name, iface := range i {
+		c[name] = iface.clone()
+	}
+	return c
+}
+
+// Network returns the state of the network collector.
+func (s *Sample) Network() Interfaces {
+	return stateOf(s, "network", func() Interfaces { return nil })
+}
+
+// netCounters are the byte, packet, error and drop counters in the order
+// of Interface.rates.
+type netCounters [8]Counter
+
+// NetworkCollector reads the interfaces in /sys/class/net and their
+// counters. The loopback device is left out, and so are interfaces that
+// never received anything unless they are backed by a device: an
+// unplugged ethernet port is listed as down, unused virtual interfaces
+// are not listed at all.
+type NetworkCollector struct {
+	last map[string]*netCounters
+	gap  time.Duration
+}
+
+func (c *NetworkCollector) Name() string            { return "network" }
+func (c *NetworkCollector) Interval() time.Duration { return time.Second * 5 }
+
+func (c *NetworkCollector) SetInterval(interval time.Duration) {
+	c.gap = rateGap(interval)
+	for _, last := range c.last {
+		for i := range last {
+			last[i].MaxGap = c.gap
+		}
+	}
+}
+
+func (c *NetworkCollector) Collect(s *Sample) error {
+	entries, err := ReadDir("/sys/class/net")
+	if err != nil {
+		return err
+	}
+	// the link state is still reported without counters
+	stats, err := psutil_net.IOCounters(true)
+	counters := make(map[string]psutil_net.IOCountersStat, len(stats))
+	for _, v := range stats {
+		counters[v.Name] = v
+	}
+	now := time.Now()
+
+	interfaces := ensureState(s, "network", func() Interfaces { return Interfaces{} })
+
+	wireless, _ := readWireless()
+
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		name := entry.Name()
+		v, counted := counters[name]
+		if name == "lo" || v.BytesRecv == 0 && !Exists("/sys/class/net/"+name+"/device") {
+			continue
+		}
+		seen[name] = true
+
+		iface, exists := interfaces[name]
+		if !exists {
+			iface = newInterface(name)
+			interfaces[name] = iface
+		}
+		iface.BytesRecv, iface.BytesSent = v.BytesRecv, v.BytesSent
+		iface.PacketsRecv, iface.PacketsSent = v.PacketsRecv, v.PacketsSent
+		iface.ErrorsRecv, iface.ErrorsSent = v.Errin, v.Errout
+		iface.DropsRecv, iface.DropsSent = v.Dropin, v.Dropout
+		readInterfaceDetails(iface)
+		if w, ok := wireless[name]; ok {
+			iface.Wireless = true
+			iface.LinkQuality.Push(w.quality)
+			iface.SignalLevel.Push(w.level)
+		} else {
+			iface.Wireless = false
+		}
+
+		if !counted {
+			continue
+		}
+		last, ok := c.last[name]
+		if !ok {
+			last = &netCounters{}
+			for i := range last {
+				last[i].MaxGap = c.gap
+			}
+			c.last[name] = last
+		}
+		values := [...]uint64{
+			v.BytesRecv, v.BytesSent, v.PacketsRecv, v.PacketsSent,
+			v.Errin, v.Errout, v.Dropin, v.Dropout,
+		}
+		for i, series := range iface.rates() {
+			last[i].Push(series, now, values[i])
+		}
+	}
+
+	for name := range interfaces {
+		if !seen[name] {
+			delete(interfaces, name)
+			delete(c.last, name)
+		}
+	}
+	return err
+}
+
+// readInterfaceDetails reads the link state from /sys/class/net and the
+// addresses of an interface.
+func readInterfaceDetails(iface *Interface) {
+	iface.OperState, _ = ReadString("/sys/class/net/" + iface.Name + "/operstate")
+	// reading carrier fails with EINVAL while the interface is down
+	carrier, err := ReadString("/sys/class/net/" + iface.Name + "/carrier")
+	iface.Carrier = err == nil && carrier == "1"
+
+	iface.IPv4, iface.IPv6 = nil, nil
+	ifi, err := net.InterfaceByName(iface.Name)
+	if err != nil {
+		return
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return
+	}
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ipnet.IP.To4() != nil {
+			iface.IPv4 = append(iface.IPv4, ipnet.String())
+		} else {
+			iface.IPv6 = append(iface.IPv6, ipnet.String())
+		}
+	}
+}
+
+type wirelessLink struct {
+	quality float64
+	level   float64
+}
+
+// readWireless parses /proc/net/wireless:
+//
+//	Inter-| sta-|   Quality        |   Discarded packets               | Missed | WE
+//	 face | tus | link level noise |  nwid  crypt   frag  retry   misc | beacon | 22
+//	wlp3s0: 0000   54.  -56.  -256        0      0      0      0     12        0
+func readWireless() (map[string]wirelessLink, error) {
+	buf, err := ReadFile("/proc/net/wireless")
+	if err != nil {
+		return nil, err
+	}
+
+	links := map[string]wirelessLink{}
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for line := 0; scanner.Scan(); line++ {
+		if line < 2 {
+			continue
+		}
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fields := strings.Fields(parts[1])
+		if len(fields) < 3 {
+			continue
+		}
+		quality, err1 := strconv.ParseFloat(strings.TrimSuffix(fields[1], "."), 64)
+		level, err2 := strconv.ParseFloat(strings.TrimSuffix(fields[2], "."), 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		links[strings.TrimSpace(parts[0])] = wirelessLink{quality: quality, level: level}
+	}
+	return links, scanner.Err()
+}
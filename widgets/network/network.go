@@ -0,0 +1,100 @@
+package network
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+	"strings"
+
+	"github.com/lian/gonky/shader"
+	"github.com/lian/gonky/texture"
+	"github.com/lian/gonky/widgets"
+	"github.com/llgcode/draw2d/draw2dimg"
+	"github.com/llgcode/draw2d/draw2dkit"
+
+	font "github.com/lian/gonky/font/terminus"
+)
+
+// Network lists every interface with its link state, addresses and, for
+// wireless interfaces, the link quality and signal level.
+type Network struct {
+	Texture *texture.Texture
+	Stats   *widgets.Stats
+
+	FontPadding int
+	// Names maps interface names to the aliases shown instead.
+	Names map[string]string
+
+	Background color.RGBA
+	Foreground color.RGBA
+}
+
+func New(program *shader.Program, stats *widgets.Stats, x, y, width, height float64) *Network {
+	n := &Network{
+		Texture:     &texture.Texture{X: x, Y: y, Width: width, Height: height},
+		Stats:       stats,
+		FontPadding: 3,
+		Names:       map[string]string{},
+		Background:  color.RGBA{0x33, 0x33, 0x33, 0xff},
+		Foreground:  color.RGBA{0x99, 0x99, 0x99, 0xff},
+	}
+	n.Texture.Setup(program)
+	return n
+}
+
+func (n *Network) Render() {
+	data := image.NewRGBA(image.Rect(0, 0, int(n.Texture.Width), int(n.Texture.Height)))
+	gc := draw2dimg.NewGraphicContext(data)
+
+	gc.SetFillColor(n.Background)
+	draw2dkit.Rectangle(gc, 0, 0, n.Texture.Width, n.Texture.Height)
+	gc.Fill()
+
+	interfaces := n.Stats.Snapshot().Network()
+	names := make([]string, 0, len(interfaces))
+	for name := range interfaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	textColor := n.Foreground
+	downColor := color.RGBA{0xcc, 0x66, 0x00, 0xff}
+
+	y := n.FontPadding
+	for _, name := range names {
+		iface := interfaces[name]
+		if alias, ok := n.Names[name]; ok {
+			name = alias
+		}
+
+		c := textColor
+		if !iface.Carrier {
+			c = downColor
+		}
+		font.DrawString(data, font.Width, y, LinkText(name, iface), c)
+		y += font.Height
+
+		for _, addr := range append(iface.IPv4, iface.IPv6...) {
+			font.DrawString(data, font.Width*3, y, addr, textColor)
+			y += font.Height
+		}
+	}
+
+	n.Texture.Write(&data.Pix)
+}
+
+// LinkText formats the state of an interface, like
+// "wifi up q 54 -56dBm rx 12.0K tx 1.5K".
+func LinkText(name string, iface *widgets.Interface) string {
+	state := iface.OperState
+	if state == "" {
+		state = "unknown"
+	}
+	parts := []string{fmt.Sprintf("%-8.8s %-7s", name, state)}
+	if iface.Wireless {
+		parts = append(parts, fmt.Sprintf("q %.0f %.0fdBm", iface.LinkQuality.Last(), iface.SignalLevel.Last()))
+	}
+	parts = append(parts, fmt.Sprintf("rx %.1fK tx %.1fK", iface.RateRecv.Last()/1024, iface.RateSent.Last()/1024))
+	return strings.Join(parts, " ")
+}
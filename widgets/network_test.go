@@ -0,0 +1,71 @@
+package widgets
+
+import "testing"
+
+func TestNetworkCollector(t *testing.T) {
+	useRoot(t, fixtureRoot)
+	c := &NetworkCollector{last: map[string]*netCounters{}}
+	s := &Sample{}
+	for i := 0; i < 2; i++ {
+		if err := c.Collect(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// lo and the unused virtual docker0 are left out
+	wifi, lan := s.Network()["wlp3s0"], s.Network()["enp0s25"]
+	if len(s.Network()) != 2 || wifi == nil || lan == nil {
+		t.Fatalf("interfaces %v, want wlp3s0 and enp0s25", s.Network())
+	}
+	if wifi.BytesRecv != 5000000 || wifi.BytesSent != 300000 || wifi.PacketsSent != 2000 || wifi.ErrorsRecv != 1 || wifi.DropsRecv != 2 {
+		t.Errorf("wlp3s0 counters %+v", wifi)
+	}
+	// nothing changed between the two readings
+	if wifi.RateRecv.Len() != 1 || wifi.RateRecv.Last() != 0 {
+		t.Errorf("wlp3s0 receive rate %v, want [0]", wifi.RateRecv.Newest(-1))
+	}
+	if name := lan.DropRateSent.Name(); name != "net.enp0s25.tx_drops" {
+		t.Errorf("series name %q", name)
+	}
+}
+
+func TestNetworkLinkState(t *testing.T) {
+	useRoot(t, fixtureRoot)
+	c := &NetworkCollector{last: map[string]*netCounters{}}
+	s := &Sample{}
+	if err := c.Collect(s); err != nil {
+		t.Fatal(err)
+	}
+
+	// the unplugged enp0s25 is still listed, as down
+	wifi, lan := s.Network()["wlp3s0"], s.Network()["enp0s25"]
+	if wifi == nil || lan == nil {
+		t.Fatalf("interfaces %v, want wlp3s0 and enp0s25", s.Network())
+	}
+	if wifi.OperState != "up" || !wifi.Carrier || !wifi.Wireless || wifi.LinkQuality.Last() != 54 || wifi.SignalLevel.Last() != -56 {
+		t.Errorf("wlp3s0 is %q, carrier %v, wireless %v, signal %v", wifi.OperState, wifi.Carrier, wifi.Wireless, wifi.SignalLevel.Last())
+	}
+	if lan.OperState != "down" || lan.Carrier || lan.Wireless || lan.SignalLevel.Len() != 0 {
+		t.Errorf("enp0s25 is %q, carrier %v, wireless %v", lan.OperState, lan.Carrier, lan.Wireless)
+	}
+}
+
+func TestReadWireless(t *testing.T) {
+	useRoot(t, fixtureRoot)
+	links, err := readWireless()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// wlan1 has a malformed quality and is skipped
+	if len(links) != 1 {
+		t.Fatalf("links %v, want only wlp3s0", links)
+	}
+	if link := links["wlp3s0"]; link.quality != 54 || link.level != -56 {
+		t.Errorf("wlp3s0 quality %v, level %v", link.quality, link.level)
+	}
+
+	useRoot(t, t.TempDir())
+	if _, err := readWireless(); err == nil {
+		t.Error("no error without /proc/net/wireless")
+	}
+}
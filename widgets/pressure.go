@@ -0,0 +1,165 @@
+package widgets
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterCollector("pressure", func() Collector { return &PressureCollector{} })
+}
+
+// PressureResources are the resources the kernel reports stall
+// information for.
+var PressureResources = []string{"cpu", "memory", "io"}
+
+// PressureAverages are the share of time in percent that tasks were
+// stalled, averaged over 10 seconds, 1 and 5 minutes.
+type PressureAverages struct {
+	Avg10  *Series[float64]
+	Avg60  *Series[float64]
+	Avg300 *Series[float64]
+}
+
+// Pressure is the Pressure Stall Information of one resource. Some is the
+// time at least one task was stalled, Full the time all of them were.
+type Pressure struct {
+	Resource string
+	Some     PressureAverages
+	Full     PressureAverages
+}
+
+func newPressureAverages(name string) PressureAverages {
+	return PressureAverages{
+		Avg10:  NewSeries[float64](name+".avg10", SeriesCapacity),
+		Avg60:  NewSeries[float64](name+".avg60", SeriesCapacity),
+		Avg300: NewSeries[float64](name+".avg300", SeriesCapacity),
+	}
+}
+
+func (p PressureAverages) clone() PressureAverages {
+	return PressureAverages{Avg10: p.Avg10.Clone(), Avg60: p.Avg60.Clone(), Avg300: p.Avg300.Clone()}
+}
+
+func (p *Pressure) metrics() []Metric {
+	return []Metric{p.Some.Avg10, p.Some.Avg60, p.Some.Avg300, p.Full.Avg10, p.Full.Avg60, p.Full.Avg300}
+}
+
+func (p *Pressure) clone() *Pressure {
+	return &Pressure{Resource: p.Resource, Some: p.Some.clone(), Full: p.Full.clone()}
+}
+
+// Pressures is the state of the PressureCollector, keyed by resource. It
+// is empty without PSI support.
+type Pressures map[string]*Pressure
+
+func (p Pressures) Metrics() []Metric {
+	var metrics []Metric
+	for _, resource := range PressureResources {
+		if pressure, ok := p[resource]; ok {
+			metrics = append(metrics, pressure.metrics()...)
+		}
+	}
+	return metrics
+}
+
+func (p Pressures) Clone() State {
+	c := make(Pressures, len(p))
+	for resource, pressure := range p {
+		c[resource] = pressure.clone()
+	}
+	return c
+}
+
+// Pressure returns the state of the pressure collector.
+func (s *Sample) Pressure() Pressures {
+	return stateOf(s, "pressure", func() Pressures { return nil })
+}
+
+var errPressureUnavailable = errors.New("pressure stall information is not available")
+
+// PressureCollector reads /proc/pressure. On kernels without PSI, or with
+// it disabled, it leaves Sample.Pressure empty.
+type PressureCollector struct{}
+
+func (c *PressureCollector) Name() string            { return "pressure" }
+func (c *PressureCollector) Interval() time.Duration { return time.Second * 5 }
+
+func (c *PressureCollector) Collect(s *Sample) error {
+	pressures := ensureState(s, "pressure", func() Pressures { return Pressures{} })
+
+	found := false
+	for _, resource := range PressureResources {
+		buf, err := ReadString("/proc/pressure/" + resource)
+		if err != nil {
+			// missing without CONFIG_PSI, EOPNOTSUPP with psi=0
+			delete(pressures, resource)
+			continue
+		}
+
+		p, ok := pressures[resource]
+		if !ok {
+			p = &Pressure{
+				Resource: resource,
+				Some:     newPressureAverages("psi." + resource + ".some"),
+				Full:     newPressureAverages("psi." + resource + ".full"),
+			}
+			pressures[resource] = p
+		}
+
+		for _, line := range strings.Split(buf, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			var averages PressureAverages
+			switch fields[0] {
+			case "some":
+				averages = p.Some
+			case "full":
+				averages = p.Full
+			default:
+				continue
+			}
+			if err := parsePressure(fields[1:], averages); err != nil {
+				return fmt.Errorf("/proc/pressure/%s: %v", resource, err)
+			}
+		}
+		found = true
+	}
+
+	if !found {
+		return errPressureUnavailable
+	}
+	return nil
+}
+
+// parsePressure parses "avg10=0.12 avg60=0.05 avg300=0.01 total=12345".
+func parsePressure(fields []string, averages PressureAverages) error {
+	for _, field := range fields {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		var series *Series[float64]
+		switch kv[0] {
+		case "avg10":
+			series = averages.Avg10
+		case "avg60":
+			series = averages.Avg60
+		case "avg300":
+			series = averages.Avg300
+		default:
+			continue
+		}
+		value, err := strconv.ParseFloat(kv[1], 64)
+		if err != nil {
+			return err
+		}
+		series.Push(value)
+	}
+	return nil
+}
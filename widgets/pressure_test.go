@@ -0,0 +1,46 @@
+package widgets
+
+import "testing"
+
+func TestPressureCollector(t *testing.T) {
+	useRoot(t, fixtureRoot)
+	s := &Sample{}
+	if err := (&PressureCollector{}).Collect(s); err != nil {
+		t.Fatal(err)
+	}
+
+	// the fixture has no io file, like a kernel that does not report it
+	if len(s.Pressure()) != 2 || s.Pressure()["io"] != nil {
+		t.Fatalf("pressure of %d resources, want cpu and memory", len(s.Pressure()))
+	}
+	cpu, memory := s.Pressure()["cpu"], s.Pressure()["memory"]
+	if cpu.Some.Avg10.Last() != 1.5 || cpu.Some.Avg60.Last() != 0.75 || cpu.Some.Avg300.Last() != 0.2 {
+		t.Errorf("cpu some %v %v %v", cpu.Some.Avg10.Last(), cpu.Some.Avg60.Last(), cpu.Some.Avg300.Last())
+	}
+	if memory.Full.Avg10.Last() != 6.5 || memory.Some.Avg10.Last() != 12 {
+		t.Errorf("memory full %v, some %v", memory.Full.Avg10.Last(), memory.Some.Avg10.Last())
+	}
+	if name := memory.Full.Avg300.Name(); name != "psi.memory.full.avg300" {
+		t.Errorf("series name %q", name)
+	}
+}
+
+func TestPressureUnavailable(t *testing.T) {
+	useRoot(t, t.TempDir())
+	s := &Sample{}
+	if err := (&PressureCollector{}).Collect(s); err != errPressureUnavailable {
+		t.Errorf("Collect = %v, want %v", err, errPressureUnavailable)
+	}
+	if len(s.Pressure()) != 0 {
+		t.Errorf("pressure %v without PSI", s.Pressure())
+	}
+}
+
+func TestPressureMalformed(t *testing.T) {
+	useRoot(t, writeFixture(t, map[string]string{
+		"/proc/pressure/cpu": "some avg10=x avg60=0.75 avg300=0.20 total=123456\n",
+	}))
+	if err := (&PressureCollector{}).Collect(&Sample{}); err == nil {
+		t.Error("no error for a malformed average")
+	}
+}
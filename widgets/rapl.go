@@ -0,0 +1,131 @@
+package widgets
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterCollector("rapl", func() Collector {
+		return &RaplCollector{counters: map[string]*Counter{}}
+	})
+}
+
+const powercapPath = "/sys/class/powercap"
+
+var errRaplUnavailable = errors.New("no readable RAPL energy counters")
+
+// RaplPower is the state of the RaplCollector, the draw in watts of every
+// domain.
+type RaplPower map[string]*Series[float64]
+
+func (p RaplPower) Metrics() []Metric {
+	var metrics []Metric
+	for _, series := range p {
+		metrics = append(metrics, series)
+	}
+	return metrics
+}
+
+func (p RaplPower) Clone() State {
+	c := make(RaplPower, len(p))
+	for name, series := range p {
+		c[name] = series.Clone()
+	}
+	return c
+}
+
+// Power returns the state of the rapl collector.
+func (s *Sample) Power() RaplPower {
+	return stateOf(s, "rapl", func() RaplPower { return nil })
+}
+
+// RaplCollector turns the energy counters of the Intel RAPL powercap
+// domains into watts. The domains are keyed like "package-0" and
+// "package-0.core". Since Linux 5.10 energy_uj is only readable by root,
+// without access Sample.Power stays empty.
+type RaplCollector struct {
+	counters map[string]*Counter
+	gap      time.Duration
+}
+
+func (c *RaplCollector) Name() string            { return "rapl" }
+func (c *RaplCollector) Interval() time.Duration { return time.Second * 5 }
+
+func (c *RaplCollector) SetInterval(interval time.Duration) {
+	c.gap = rateGap(interval)
+	for _, counter := range c.counters {
+		counter.MaxGap = c.gap
+	}
+}
+
+func (c *RaplCollector) Collect(s *Sample) error {
+	power := ensureState(s, "rapl", func() RaplPower { return RaplPower{} })
+
+	dirs, err := Glob(powercapPath + "/intel-rapl:*")
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		name, ok := raplDomain(dir)
+		if !ok {
+			continue
+		}
+		now := time.Now()
+		energy, err := readUint(filepath.Join(dir, "energy_uj"))
+		if err != nil {
+			continue
+		}
+		seen[name] = true
+
+		counter, ok := c.counters[name]
+		if !ok {
+			counter = &Counter{MaxGap: c.gap}
+			counter.Wrap, _ = readUint(filepath.Join(dir, "max_energy_range_uj"))
+			c.counters[name] = counter
+		}
+		series, ok := power[name]
+		if !ok {
+			series = NewSeries[float64]("power."+name, SeriesCapacity)
+			power[name] = series
+		}
+
+		// µJ per second to W
+		if rate, ok := counter.Update(now, energy); ok {
+			series.PushAt(now, rate/1e6)
+		}
+	}
+
+	for name := range power {
+		if !seen[name] {
+			delete(power, name)
+			delete(c.counters, name)
+		}
+	}
+	if len(seen) == 0 && len(dirs) > 0 {
+		return errRaplUnavailable
+	}
+	return nil
+}
+
+// raplDomain returns the name of the domain in dir, prefixed with its
+// package for subdomains like intel-rapl:0:0.
+func raplDomain(dir string) (string, bool) {
+	name, err := ReadString(filepath.Join(dir, "name"))
+	if err != nil {
+		return "", false
+	}
+	parts := strings.Split(filepath.Base(dir), ":")
+	if len(parts) == 3 {
+		parent, err := ReadString(filepath.Join(filepath.Dir(dir), parts[0]+":"+parts[1], "name"))
+		if err != nil {
+			return "", false
+		}
+		name = parent + "." + name
+	}
+	return name, true
+}
@@ -0,0 +1,103 @@
+package widgets
+
+import "time"
+
+// MaxRateGap is the longest time between two readings of a counter that
+// still yields a rate, unless the counter has its own MaxGap. A longer
+// gap, for example after the scheduler was stalled, averages over too
+// much to be shown as the current rate.
+var MaxRateGap = time.Minute
+
+// rateGapIntervals is how many polling intervals of a collector may pass
+// between two readings of its counters before the rate is dropped.
+const rateGapIntervals = 3
+
+// rateGap is the MaxGap of the counters of a collector that is polled
+// every interval, at least MaxRateGap.
+func rateGap(interval time.Duration) time.Duration {
+	if gap := rateGapIntervals * interval; gap > MaxRateGap {
+		return gap
+	}
+	return MaxRateGap
+}
+
+// suspendSlack is how far the wall clock may run ahead of the monotonic
+// clock between two readings before they are taken to span a suspend.
+const suspendSlack = 2 * time.Second
+
+// Counter turns successive readings of a monotonic counter, like the
+// bytes received by an interface, into a per second rate.
+//
+// The elapsed time is measured on the monotonic clock, so wall clock
+// changes do not distort the rate. The monotonic clock stops during a
+// suspend while the wall clock keeps going, which is how a reading across
+// a suspend is recognised and dropped: drivers often reset their counters
+// on resume.
+type Counter struct {
+	// Wrap is the largest value of a counter that goes on at 0 after
+	// it, like the max_energy_range_uj of a RAPL domain. A counter
+	// without one that goes backwards is taken as reset.
+	Wrap uint64
+	// MaxGap replaces MaxRateGap for counters that are read less often
+	// than that, it is set from the interval of their collector.
+	MaxGap time.Duration
+
+	last     uint64
+	lastAt   time.Time
+	lastWall time.Time
+	seen     bool
+}
+
+// Update records a reading taken at the given time and returns the rate
+// since the previous one. ok is false when there is no trustworthy rate:
+// for the first reading, after a reset, across a suspend and when the
+// readings are further than MaxGap or MaxRateGap apart. The next reading is then
+// compared against this one again.
+func (c *Counter) Update(at time.Time, value uint64) (rate float64, ok bool) {
+	// Round(0) strips the monotonic reading, leaving the wall time
+	return c.update(at, at.Round(0), value)
+}
+
+// update is Update with the time of the reading on the monotonic and on
+// the wall clock passed separately.
+func (c *Counter) update(at, wall time.Time, value uint64) (float64, bool) {
+	last, lastAt, lastWall, seen := c.last, c.lastAt, c.lastWall, c.seen
+	c.last, c.lastAt, c.lastWall, c.seen = value, at, wall, true
+	if !seen {
+		return 0, false
+	}
+
+	maxGap := c.MaxGap
+	if maxGap == 0 {
+		maxGap = MaxRateGap
+	}
+	elapsed := at.Sub(lastAt)
+	if elapsed <= 0 || elapsed > maxGap {
+		return 0, false
+	}
+	if wall.Sub(lastWall)-elapsed > suspendSlack {
+		return 0, false
+	}
+
+	var delta uint64
+	switch {
+	case value >= last:
+		delta = value - last
+	case c.Wrap > 0 && last <= c.Wrap:
+		// the counter went from last to Wrap, on to 0 and up to value
+		delta = c.Wrap - last + value + 1
+	default:
+		return 0, false
+	}
+	return float64(delta) / elapsed.Seconds(), true
+}
+
+// Push updates the counter and pushes the rate onto series, unless the
+// rate cannot be trusted. It reports whether a value was pushed.
+func (c *Counter) Push(series *Series[float64], at time.Time, value uint64) bool {
+	rate, ok := c.Update(at, value)
+	if ok {
+		series.PushAt(at, rate)
+	}
+	return ok
+}
@@ -0,0 +1,120 @@
+package widgets
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCounterUpdate(t *testing.T) {
+	type reading struct {
+		// after is the time since the previous reading, suspended how
+		// much of it the machine was asleep, which the wall clock counts
+		// and the monotonic clock does not
+		after     time.Duration
+		suspended time.Duration
+		value     uint64
+		rate      float64
+		ok        bool
+	}
+
+	tests := []struct {
+		name     string
+		wrap     uint64
+		readings []reading
+	}{
+		{"first reading", 0, []reading{
+			{0, 0, 100, 0, false},
+		}},
+		{"steady", 0, []reading{
+			{0, 0, 100, 0, false},
+			{time.Second, 0, 1100, 1000, true},
+			{2 * time.Second, 0, 2100, 500, true},
+			{time.Second, 0, 2100, 0, true},
+		}},
+		{"reset", 0, []reading{
+			{0, 0, 5000, 0, false},
+			{time.Second, 0, 200, 0, false},
+			// the reading after the reset is compared to the reset
+			{time.Second, 0, 700, 500, true},
+		}},
+		{"wrap", 1000, []reading{
+			{0, 0, 900, 0, false},
+			// 900 to 1000 is 100, wrapping to 0 is 1 more and 0 to 49 is 49
+			{time.Second, 0, 49, 150, true},
+			{time.Second, 0, 149, 100, true},
+		}},
+		{"wrap to zero", 1000, []reading{
+			{0, 0, 1000, 0, false},
+			{time.Second, 0, 0, 1, true},
+		}},
+		{"last reading above wrap is a reset", 1000, []reading{
+			{0, 0, 5000, 0, false},
+			{time.Second, 0, 10, 0, false},
+		}},
+		{"suspend", 0, []reading{
+			{0, 0, 100, 0, false},
+			{5 * time.Second, time.Hour, 200, 0, false},
+			{5 * time.Second, 0, 700, 100, true},
+		}},
+		{"clock jitter is no suspend", 0, []reading{
+			{0, 0, 100, 0, false},
+			{5 * time.Second, suspendSlack, 600, 100, true},
+		}},
+		{"gap", 0, []reading{
+			{0, 0, 100, 0, false},
+			{MaxRateGap + time.Second, 0, 200, 0, false},
+			{time.Second, 0, 300, 100, true},
+		}},
+		{"same time", 0, []reading{
+			{0, 0, 100, 0, false},
+			{0, 0, 200, 0, false},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &Counter{Wrap: test.wrap}
+			at := time.Unix(1700000000, 0)
+			wall := at
+			for i, r := range test.readings {
+				at = at.Add(r.after)
+				wall = wall.Add(r.after + r.suspended)
+				rate, ok := c.update(at, wall, r.value)
+				if rate != r.rate || ok != r.ok {
+					t.Errorf("reading %d: got %v, %v, want %v, %v", i, rate, ok, r.rate, r.ok)
+				}
+			}
+		})
+	}
+}
+
+func TestCounterPush(t *testing.T) {
+	c := &Counter{}
+	series := NewSeries[float64]("rate", 10)
+	now := time.Now()
+	if c.Push(series, now, 10) || series.Len() != 0 {
+		t.Fatal("the first reading pushed a rate")
+	}
+	if !c.Push(series, now.Add(2*time.Second), 30) || series.Last() != 10 {
+		t.Fatalf("pushed %v, want 10", series.Last())
+	}
+}
+
+func TestCounterMaxGap(t *testing.T) {
+	if gap := rateGap(5 * time.Second); gap != MaxRateGap {
+		t.Errorf("gap for a 5s interval is %v, want MaxRateGap", gap)
+	}
+
+	// polled every 2 minutes
+	c := &Counter{MaxGap: rateGap(2 * time.Minute)}
+	at := time.Unix(1700000000, 0)
+	c.update(at, at, 100)
+	at = at.Add(2 * time.Minute)
+	if rate, ok := c.update(at, at, 220); !ok || rate != 1 {
+		t.Errorf("after one interval got %v, %v, want 1", rate, ok)
+	}
+	at = at.Add(7 * time.Minute)
+	if _, ok := c.update(at, at, 640); ok {
+		t.Error("rate across more than three missed intervals")
+	}
+}
@@ -0,0 +1,295 @@
+package widgets
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Number is the set of value types a Series can hold.
+type Number interface {
+	~int | ~int64 | ~uint64 | ~float64
+}
+
+// Metric is the part of a Series that does not depend on its value type.
+// Levels address the raw values as level 0 and the tiers as 1 and up.
+type Metric interface {
+	Name() string
+	// Latest returns the newest value and Bounds the smallest and largest
+	// of the newest n values.
+	Latest() float64
+	Bounds(n int) (min, max float64)
+	// Floats returns the newest n values, oldest first.
+	Floats(n int) []float64
+	LastAt() time.Time
+	Buckets(span time.Duration) []Bucket
+	// Since returns the raw values (as single value buckets) or the
+	// completed tier buckets of a level that are newer than since.
+	Since(level int, since time.Time) []Bucket
+	// Partial returns the tier bucket of a level that is still being
+	// filled.
+	Partial(level int) (Bucket, bool)
+	// Restore adds a previously recorded bucket back into a level.
+	Restore(level int, b Bucket)
+}
+
+// Series is a fixed capacity ring buffer of metric values. Once it is full
+// every Push overwrites the oldest value, so it never reallocates. Min and
+// Max are tracked over every value ever pushed, restored history included,
+// Bounds only covers the values still in the buffer.
+//
+// Next to the raw values every series keeps the DefaultTiers, which
+// average the values over longer periods for views like "last day".
+type Series[T Number] struct {
+	name string
+
+	values []T
+	times  []time.Time
+	head   int
+
+	last   T
+	lastAt time.Time
+	min    T
+	max    T
+	count  int
+
+	tiers []Tier
+}
+
+// NewSeries returns an empty series that keeps the newest capacity values.
+// The name identifies the series in the history store.
+func NewSeries[T Number](name string, capacity int) *Series[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	s := &Series[T]{
+		name:   name,
+		values: make([]T, 0, capacity),
+		times:  make([]time.Time, 0, capacity),
+	}
+	for _, spec := range DefaultTiers {
+		s.tiers = append(s.tiers, newTier(spec))
+	}
+	return s
+}
+
+// Name returns the name the series was created with.
+func (s *Series[T]) Name() string { return s.name }
+
+// Push appends a value, dropping the oldest one when the series is full.
+func (s *Series[T]) Push(v T) {
+	s.PushAt(time.Now(), v)
+}
+
+// PushAt is Push for a value that was read at the given time.
+func (s *Series[T]) PushAt(at time.Time, v T) {
+	for i := range s.tiers {
+		s.tiers[i].add(at, float64(v))
+	}
+	s.push(at, v)
+}
+
+func (s *Series[T]) push(at time.Time, v T) {
+	if len(s.values) < cap(s.values) {
+		s.values = append(s.values, v)
+		s.times = append(s.times, at)
+	} else {
+		s.values[s.head] = v
+		s.times[s.head] = at
+		s.head = (s.head + 1) % len(s.values)
+	}
+
+	s.track(v)
+	s.last = v
+	s.lastAt = at
+}
+
+func (s *Series[T]) track(v T) {
+	if s.count == 0 || v < s.min {
+		s.min = v
+	}
+	if s.count == 0 || v > s.max {
+		s.max = v
+	}
+	s.count++
+}
+
+// Len returns the number of values in the buffer.
+func (s *Series[T]) Len() int { return len(s.values) }
+
+// Cap returns the number of values the buffer can hold.
+func (s *Series[T]) Cap() int { return cap(s.values) }
+
+// LastAt returns the time the newest value was pushed at.
+func (s *Series[T]) LastAt() time.Time { return s.lastAt }
+
+// Count returns the number of values pushed so far.
+func (s *Series[T]) Count() int { return s.count }
+
+// Last returns the newest value, or zero when the series is empty.
+func (s *Series[T]) Last() T { return s.last }
+
+// Min returns the smallest value pushed so far.
+func (s *Series[T]) Min() T { return s.min }
+
+// Max returns the largest value pushed so far.
+func (s *Series[T]) Max() T { return s.max }
+
+// Each calls fn for the newest n values, oldest first, with i counting up
+// from 0. A negative n or one larger than Len iterates over all values.
+func (s *Series[T]) Each(n int, fn func(i int, v T)) {
+	s.each(n, func(i int, at time.Time, v T) {
+		fn(i, v)
+	})
+}
+
+func (s *Series[T]) each(n int, fn func(i int, at time.Time, v T)) {
+	if n < 0 || n > len(s.values) {
+		n = len(s.values)
+	}
+	start := len(s.values) - n
+	for i := 0; i < n; i++ {
+		j := (s.head + start + i) % len(s.values)
+		fn(i, s.times[j], s.values[j])
+	}
+}
+
+// Newest returns a copy of the newest n values, oldest first.
+func (s *Series[T]) Newest(n int) []T {
+	var values []T
+	s.Each(n, func(i int, v T) {
+		values = append(values, v)
+	})
+	return values
+}
+
+func (s *Series[T]) Latest() float64 { return float64(s.last) }
+
+func (s *Series[T]) Bounds(n int) (min, max float64) {
+	s.Each(n, func(i int, v T) {
+		f := float64(v)
+		if i == 0 || f < min {
+			min = f
+		}
+		if i == 0 || f > max {
+			max = f
+		}
+	})
+	return min, max
+}
+
+func (s *Series[T]) Floats(n int) []float64 {
+	var values []float64
+	s.Each(n, func(i int, v T) {
+		values = append(values, float64(v))
+	})
+	return values
+}
+
+// Tiers returns the downsampled tiers, finest resolution first.
+func (s *Series[T]) Tiers() []Tier { return s.tiers }
+
+// Buckets returns the values of the last span before the newest value,
+// downsampled by the finest tier that retains the whole span. It returns
+// nil when no tier does.
+func (s *Series[T]) Buckets(span time.Duration) []Bucket {
+	for i := range s.tiers {
+		if s.tiers[i].Retention >= span {
+			return s.tiers[i].Buckets(s.lastAt.Add(-span))
+		}
+	}
+	return nil
+}
+
+func (s *Series[T]) Since(level int, since time.Time) []Bucket {
+	if level > 0 {
+		if level > len(s.tiers) {
+			return nil
+		}
+		return s.tiers[level-1].Completed(since)
+	}
+
+	var buckets []Bucket
+	s.each(-1, func(i int, at time.Time, v T) {
+		if at.After(since) {
+			f := float64(v)
+			buckets = append(buckets, Bucket{Start: at, Avg: f, Min: f, Max: f, Count: 1})
+		}
+	})
+	return buckets
+}
+
+func (s *Series[T]) Partial(level int) (Bucket, bool) {
+	if level < 1 || level > len(s.tiers) {
+		return Bucket{}, false
+	}
+	current := s.tiers[level-1].current
+	return current, current.Count > 0
+}
+
+func (s *Series[T]) Restore(level int, b Bucket) {
+	if level > 0 {
+		if level > len(s.tiers) {
+			return
+		}
+		s.tiers[level-1].restore(b)
+		s.track(T(b.Min))
+		s.track(T(b.Max))
+		return
+	}
+
+	v := T(b.Avg)
+	if !b.Start.Before(s.lastAt) {
+		s.push(b.Start, v)
+		return
+	}
+
+	// an older value, usually because a collection already happened
+	// before the history was loaded: rebuild the buffer in time order
+	type point struct {
+		at time.Time
+		v  T
+	}
+	points := []point{{b.Start, v}}
+	s.each(-1, func(i int, at time.Time, v T) {
+		points = append(points, point{at, v})
+	})
+	sort.SliceStable(points, func(i, j int) bool { return points[i].at.Before(points[j].at) })
+	if len(points) > cap(s.values) {
+		points = points[len(points)-cap(s.values):]
+	}
+
+	s.values = s.values[:0]
+	s.times = s.times[:0]
+	s.head = 0
+	for _, p := range points {
+		s.values = append(s.values, p.v)
+		s.times = append(s.times, p.at)
+	}
+	s.track(v)
+}
+
+// Clone returns a deep copy of the series. Completed tier buckets are
+// immutable and shared with the clone.
+func (s *Series[T]) Clone() *Series[T] {
+	c := *s
+	c.values = make([]T, len(s.values), cap(s.values))
+	copy(c.values, s.values)
+	c.times = make([]time.Time, len(s.times), cap(s.times))
+	copy(c.times, s.times)
+	c.tiers = append([]Tier(nil), s.tiers...)
+	return &c
+}
+
+// MarshalJSON encodes the name, the newest value, the bounds and the raw
+// values, oldest first. The tiers are left out.
+func (s *Series[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name   string    `json:"name"`
+		Last   T         `json:"last"`
+		LastAt time.Time `json:"last_at"`
+		Min    T         `json:"min"`
+		Max    T         `json:"max"`
+		Values []T       `json:"values"`
+	}{s.name, s.last, s.lastAt, s.min, s.max, s.Newest(-1)})
+}
@@ -0,0 +1,38 @@
+package widgets
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeriesBounds(t *testing.T) {
+	s := NewSeries[float64]("cpu", 5)
+	if min, max := s.Bounds(5); min != 0 || max != 0 {
+		t.Errorf("empty series bounds %v, %v", min, max)
+	}
+
+	// a spike restored from the history and one pushed long ago
+	s.Restore(2, Bucket{Start: time.Now().Add(-24 * time.Hour), Avg: 500, Min: 400, Max: 1000, Count: 1})
+	for _, v := range []float64{900, 3, 5, 2, 8, 4} {
+		s.Push(v)
+	}
+
+	tests := []struct {
+		n        int
+		min, max float64
+	}{
+		{5, 2, 8},
+		{-1, 2, 8},
+		{100, 2, 8},
+		{2, 4, 8},
+		{1, 4, 4},
+	}
+	for _, test := range tests {
+		if min, max := s.Bounds(test.n); min != test.min || max != test.max {
+			t.Errorf("Bounds(%d) = %v, %v, want %v, %v", test.n, min, max, test.min, test.max)
+		}
+	}
+	if s.Max() != 1000 {
+		t.Errorf("Max() = %v, want the all-time 1000", s.Max())
+	}
+}
@@ -4,49 +4,65 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"math"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/lian/gonky/shader"
 	"github.com/lian/gonky/texture"
 	"github.com/lian/gonky/widgets"
+	"github.com/lian/gonky/widgets/format"
 	"github.com/llgcode/draw2d/draw2dimg"
 	"github.com/llgcode/draw2d/draw2dkit"
 
 	font "github.com/lian/gonky/font/terminus"
-
-	psutil_net "github.com/shirou/gopsutil/net"
 )
 
-type Net struct {
-	Name          string
-	Time          time.Time
-	LastBytesRecv uint64
-	LastBytesSent uint64
-	RateRecv      float64
-	RateSent      float64
-}
-
 type Status struct {
-	Texture    *texture.Texture
-	Redraw     chan bool
-	Time       string
-	Network    string
-	NetworkMap map[string]*Net
-	Battery    string
-	Stats      *widgets.Stats
+	Texture *texture.Texture
+	Redraw  chan bool
+	Time    string
+	Stats   *widgets.Stats
+
+	Background color.RGBA
+	Foreground color.RGBA
+	// Template replaces the built-in layout of the bar when set.
+	Template *format.Template
+
+	// mu guards Time, which Run updates while Render reads it.
+	mu   sync.Mutex
+	done chan struct{}
 }
 
 var FontPadding int = 3
 
+// ShowLoad adds the load averages and the running/total task counts.
+var ShowLoad bool = true
+
+// ShowPressure adds the 10s PSI "some" averages, coloured by PressureColor.
+var ShowPressure bool = true
+
+// ShowDisks adds the throughput of every disk and the usage of the
+// collected filesystems.
+var ShowDisks bool = true
+
+// ShowCpuCores adds the utilisation of every core next to the total.
+var ShowCpuCores bool = false
+
+// ShowWifiSignal appends the signal level to wireless interfaces.
+var ShowWifiSignal bool = true
+
 func New(windowWidth, windowHeight int, program *shader.Program, stats *widgets.Stats) *Status {
 	height := float64(font.Height + (2 * FontPadding))
 	status := &Status{
-		Texture:    &texture.Texture{X: 0, Y: float64(windowHeight), Width: float64(windowWidth), Height: height},
-		Redraw:     make(chan bool),
-		NetworkMap: map[string]*Net{},
-		Stats:      stats,
+		Texture: &texture.Texture{X: 0, Y: float64(windowHeight), Width: float64(windowWidth), Height: height},
+		Redraw:  make(chan bool),
+		Stats:   stats,
+		done:    make(chan struct{}),
+
+		Background: color.RGBA{0xcc, 0xcc, 0xcc, 0xff},
+		Foreground: color.RGBA{0x00, 0x00, 0x00, 0xff},
 	}
 	status.Texture.Setup(program)
 	return status
@@ -56,50 +72,134 @@ func (s *Status) Render() {
 	data := image.NewRGBA(image.Rect(0, 0, int(s.Texture.Width), int(s.Texture.Height)))
 	gc := draw2dimg.NewGraphicContext(data)
 
-	gc.SetFillColor(color.RGBA{0xcc, 0xcc, 0xcc, 0xff})
+	gc.SetFillColor(s.Background)
 	draw2dkit.Rectangle(gc, 0, 0, s.Texture.Width, s.Texture.Height)
 	gc.Fill()
 
-	text_height := FontPadding
-	font.DrawString(data, font.Width, text_height, s.Time, color.Black)
+	stats := s.Stats.Snapshot()
+	if s.Template != nil {
+		s.renderTemplate(data, stats)
+		s.Texture.Write(&data.Pix)
+		return
+	}
+
+	s.mu.Lock()
+	timeText := s.Time
+	s.mu.Unlock()
 
-	thermalText := fmt.Sprintf("%dC", s.Stats.ThermalValue)
-	fanText := fmt.Sprintf("%d RPM L%d", s.Stats.FanValue, s.Stats.FanLevel)
-	memoryText := fmt.Sprintf("%.2f%% RAM", s.Stats.MemoryValue)
-	cpuText := fmt.Sprintf("%.2f%% CPU", s.Stats.CpuValue)
+	text_height := FontPadding
+	font.DrawString(data, font.Width, text_height, timeText, s.Foreground)
 
-	buf := strings.Join([]string{memoryText, fanText, thermalText, cpuText, s.Network, s.Battery}, "  |  ")
+	buf := Text(stats)
 	right := int(s.Texture.Width) - ((len(buf) * font.Width) + font.Width)
-	font.DrawString(data, right, text_height, buf, color.Black)
+	font.DrawString(data, right, text_height, buf, s.Foreground)
+
+	if ShowPressure {
+		x := right - font.Width
+		pressure := stats.Pressure()
+		for i := len(widgets.PressureResources) - 1; i >= 0; i-- {
+			p, ok := pressure[widgets.PressureResources[i]]
+			if !ok {
+				continue
+			}
+			avg := p.Some.Avg10.Last()
+			text := fmt.Sprintf("%s %.1f ", p.Resource, avg)
+			x -= len(text) * font.Width
+			font.DrawString(data, x, text_height, text, PressureColor(avg, s.Foreground))
+		}
+	}
 
 	s.Texture.Write(&data.Pix)
 }
 
-func (s *Status) Run() {
-	s.UpdateTime()
-	s.UpdateNetwork()
-	s.UpdateBattery()
-	s.Redraw <- true
+func (s *Status) renderTemplate(data *image.RGBA, stats *widgets.Sample) {
+	sections := s.Template.Execute(&format.Env{Sample: stats, Now: time.Now(), NetworkNames: NetworkNamesMap})
+	width := int(s.Texture.Width)
+
+	left := sections[format.Left]
+	font.DrawString(data, font.Width, FontPadding, left, s.Foreground)
+	center := sections[format.Center]
+	font.DrawString(data, (width-len(center)*font.Width)/2, FontPadding, center, s.Foreground)
+	right := sections[format.Right]
+	font.DrawString(data, width-(len(right)+1)*font.Width, FontPadding, right, s.Foreground)
+}
+
+// Text formats the right hand side of the status bar.
+func Text(stats *widgets.Sample) string {
+	thermalText := fmt.Sprintf("%dC", stats.Thermal().Value.Last())
+	fan := stats.Fan()
+	fanText := fmt.Sprintf("%d RPM", fan.RPM.Last())
+	if fan.Level != "" {
+		fanText += " L" + fan.Level
+	}
+	memoryText := fmt.Sprintf("%.2f%% RAM", stats.Memory().Percent.Last())
+	cpu := stats.Cpu()
+	cpuText := fmt.Sprintf("%.2f%% CPU", cpu.Total.Last())
+	if ShowCpuCores && len(cpu.Cores) > 0 {
+		cores := make([]string, len(cpu.Cores))
+		for i, core := range cpu.Cores {
+			cores[i] = fmt.Sprintf("%.0f", core.Last())
+		}
+		cpuText += " [" + strings.Join(cores, " ") + "]"
+	}
+
+	texts := []string{memoryText, fanText, thermalText, cpuText}
+	if ShowLoad {
+		texts = append(texts, LoadText(stats))
+	}
+	if ShowDisks {
+		if diskText := DiskText(stats); diskText != "" {
+			texts = append(texts, diskText)
+		}
+	}
+	texts = append(texts, NetworkText(stats), BatteryText(stats.Battery()))
+	return strings.Join(texts, "  |  ")
+}
 
+// PressureColor colour-codes a PSI average: normal while tasks are rarely
+// stalled, orange from 10% and red from 40% of the time.
+func PressureColor(avg float64, normal color.Color) color.Color {
+	switch {
+	case avg >= 40:
+		return color.RGBA{0xcc, 0x00, 0x00, 0xff}
+	case avg >= 10:
+		return color.RGBA{0xcc, 0x66, 0x00, 0xff}
+	default:
+		return normal
+	}
+}
+
+// Run updates the time every 5 seconds until Stop is called.
+func (s *Status) Run() {
 	five := time.NewTicker(time.Second * 5)
-	ten := time.NewTicker(time.Second * 10)
+	defer five.Stop()
 	for {
+		s.UpdateTime()
+		select {
+		case s.Redraw <- true:
+		case <-s.done:
+			return
+		}
+
 		select {
 		case <-five.C:
-			s.UpdateTime()
-			s.UpdateNetwork()
-			break
-		case <-ten.C:
-			s.UpdateBattery()
-			break
+		case <-s.done:
+			return
 		}
-		s.Redraw <- true
 	}
 }
 
+// Stop ends Run.
+func (s *Status) Stop() {
+	close(s.done)
+}
+
 func (s *Status) UpdateTime() {
 	//s.Time = time.Now().Format("15:04:05 02.01.2006")
-	s.Time = time.Now().Format("15:04 02.01.2006")
+	now := time.Now().Format("15:04 02.01.2006")
+	s.mu.Lock()
+	s.Time = now
+	s.mu.Unlock()
 }
 
 var NetworkNamesMap map[string]string = map[string]string{
@@ -107,72 +207,89 @@ var NetworkNamesMap map[string]string = map[string]string{
 	"wlp3s0":  "wifi",
 }
 
-func (s *Status) UpdateNetwork() {
-	stats, _ := psutil_net.IOCounters(true)
-	networks := []string{}
-
-	isAvailable := map[string]bool{}
-	for id, _ := range s.NetworkMap {
-		isAvailable[id] = false
+// NetworkText formats the receive and send rate of every interface in
+// KiB/s, using the NetworkNamesMap aliases. Interfaces without a carrier
+// are shown as down.
+func NetworkText(stats *widgets.Sample) string {
+	interfaces := stats.Network()
+	names := make([]string, 0, len(interfaces))
+	for name := range interfaces {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	for _, v := range stats {
-		if v.Name == "lo" || v.BytesRecv == 0 {
-			continue
+	networks := []string{}
+	for _, name := range names {
+		iface := interfaces[name]
+		if alias, ok := NetworkNamesMap[name]; ok {
+			name = alias
 		}
-		if alias, ok := NetworkNamesMap[v.Name]; ok {
-			v.Name = alias
+		if !iface.Carrier {
+			networks = append(networks, name+" down")
+			continue
 		}
-
-		isAvailable[v.Name] = true
-
-		var net *Net
-		var ok bool
-
-		if net, ok = s.NetworkMap[v.Name]; ok {
-			now := time.Now()
-			timeDiff := now.Sub(net.Time)
-			net.Time = now
-
-			recvDiff := math.Abs(float64(net.LastBytesRecv) - float64(v.BytesRecv))
-			sentDiff := math.Abs(float64(net.LastBytesSent) - float64(v.BytesSent))
-			net.LastBytesRecv = v.BytesRecv
-			net.LastBytesSent = v.BytesSent
-
-			net.RateRecv = recvDiff / timeDiff.Seconds()
-			net.RateSent = sentDiff / timeDiff.Seconds()
-		} else {
-			net = &Net{
-				Time:          time.Now(),
-				Name:          v.Name,
-				LastBytesRecv: v.BytesRecv,
-				LastBytesSent: v.BytesSent,
-				RateRecv:      0,
-				RateSent:      0,
-			}
-			s.NetworkMap[v.Name] = net
+		buf := fmt.Sprintf("%.1f-%s-%.1f", iface.RateRecv.Last()/1024, name, iface.RateSent.Last()/1024)
+		if ShowWifiSignal && iface.Wireless {
+			buf += fmt.Sprintf(" %.0fdBm", iface.SignalLevel.Last())
 		}
-
-		buf := fmt.Sprintf("%.1f-%s-%.1f", net.RateRecv/1024, v.Name, net.RateSent/1024)
 		networks = append(networks, buf)
 	}
+	return strings.Join(networks, " | ")
+}
 
-	for id, state := range isAvailable {
-		if state == false {
-			delete(s.NetworkMap, id)
-		}
+// BatteryText formats the combined battery state, prefixed with "ac" or
+// "bat" for the power source. Machines without a battery show only "ac".
+// The time left is the collector's estimate where there is one.
+func BatteryText(battery *widgets.Battery) string {
+	if battery == nil || battery.Status == nil {
+		return "ac"
 	}
+	source := "bat"
+	if battery.Status.OnAC {
+		source = "ac"
+	}
+	b := battery.Status.Battery
+	if b.Status == "Idle" || b.Status == "Full" {
+		return fmt.Sprintf("%s %s %.0f%%", source, strings.ToLower(b.Status), b.Percent)
+	}
+	remaining := b.Remaining
+	if battery.Estimate > 0 {
+		minutes := int(battery.Estimate.Minutes())
+		remaining = fmt.Sprintf("%.2d:%.2d", minutes/60, minutes%60)
+	}
+	return fmt.Sprintf("%s %s %sh %.1fW %.0f%%", source, strings.ToLower(b.Status), remaining, b.Watts, b.Percent)
+}
 
-	s.Network = strings.Join(networks, " | ")
+// LoadText formats the load averages and task counts like uptime and top.
+func LoadText(stats *widgets.Sample) string {
+	l := stats.Load()
+	return fmt.Sprintf("%.2f %.2f %.2f %d/%d", l.Load1.Last(), l.Load5.Last(), l.Load15.Last(), l.TasksRunning.Last(), l.TasksTotal.Last())
 }
 
-func (s *Status) UpdateBattery() {
-	b, err := ReadBattery("BAT0")
-	if err == nil {
-		if b.Status == "Idle" {
-			s.Battery = fmt.Sprintf("idle %.0f%%", b.Percent)
-		} else {
-			s.Battery = fmt.Sprintf("%s %sh %.0fmA %.0f%%", strings.ToLower(b.Status), b.Remaining, b.Amps, b.Percent)
-		}
+// DiskText formats disk throughput like the network rates, in KiB/s, and
+// the filesystem usage in percent.
+func DiskText(stats *widgets.Sample) string {
+	var texts []string
+	usage := stats.Disk()
+
+	names := make([]string, 0, len(usage.Disks))
+	for name := range usage.Disks {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	for _, name := range names {
+		disk := usage.Disks[name]
+		texts = append(texts, fmt.Sprintf("%.1f-%s-%.1f", disk.Read.Last()/1024, name, disk.Write.Last()/1024))
+	}
+
+	mounts := make([]string, 0, len(usage.Filesystems))
+	for mount := range usage.Filesystems {
+		mounts = append(mounts, mount)
+	}
+	sort.Strings(mounts)
+	for _, mount := range mounts {
+		texts = append(texts, fmt.Sprintf("%s %.0f%%", mount, usage.Filesystems[mount].Used.Last()))
+	}
+
+	return strings.Join(texts, " | ")
 }
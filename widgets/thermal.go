@@ -0,0 +1,134 @@
+package widgets
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+func init() {
+	RegisterCollector("thermal", func() Collector {
+		return &ThermalCollector{
+			source:  ThermalMax,
+			Sensors: DiscoverTemperatureSensors(),
+		}
+	})
+}
+
+const (
+	// ThermalMax reports the hottest sensor.
+	ThermalMax = "max"
+	// ThermalAverage reports the average of all sensors.
+	ThermalAverage = "average"
+)
+
+// Thermal is the state of the ThermalCollector.
+type Thermal struct {
+	// Value is the temperature selected by the Source of the collector.
+	Value *Series[int]
+	// Temperatures holds the last reading of every sensor by its Key.
+	Temperatures map[string]int
+}
+
+func newThermal() *Thermal {
+	return &Thermal{Value: NewSeries[int]("thermal", SeriesCapacity)}
+}
+
+func (t *Thermal) Metrics() []Metric { return []Metric{t.Value} }
+
+func (t *Thermal) Clone() State {
+	c := &Thermal{Value: t.Value.Clone(), Temperatures: make(map[string]int, len(t.Temperatures))}
+	for name, value := range t.Temperatures {
+		c.Temperatures[name] = value
+	}
+	return c
+}
+
+// Thermal returns the state of the thermal collector.
+func (s *Sample) Thermal() *Thermal { return stateOf(s, "thermal", newThermal) }
+
+// ThermalCollector reads the discovered hwmon temperature sensors.
+type ThermalCollector struct {
+	mu     sync.Mutex
+	source string
+
+	Sensors []TemperatureSensor
+}
+
+// SetSource selects which sensors feed Thermal.Value: ThermalMax,
+// ThermalAverage, a sensor key like "hwmon2/nvme/Composite", a sensor name
+// like "k10temp/Tctl" or a chip name like "coretemp", which reports the
+// hottest sensor of that chip. It can be called while the collector is
+// running.
+func (c *ThermalCollector) SetSource(source string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.source = source
+}
+
+func (c *ThermalCollector) Name() string            { return "thermal" }
+func (c *ThermalCollector) Interval() time.Duration { return time.Second * 5 }
+
+func (c *ThermalCollector) Collect(s *Sample) error {
+	if len(c.Sensors) == 0 {
+		return fmt.Errorf("no hwmon temperature sensors found")
+	}
+
+	c.mu.Lock()
+	source := c.source
+	c.mu.Unlock()
+
+	temperatures := map[string]int{}
+	var max, sum, count, selected int
+	found := false
+
+	for _, sensor := range c.Sensors {
+		buf, err := ReadString(sensor.Path)
+		if err != nil {
+			continue
+		}
+		milli, err := strconv.ParseInt(buf, 10, 64)
+		if err != nil {
+			continue
+		}
+		celsius := int(milli / 1000)
+		temperatures[sensor.Key()] = celsius
+
+		if celsius > max {
+			max = celsius
+		}
+		sum += celsius
+		count++
+
+		if sensor.Key() == source || sensor.Name() == source || sensor.Chip == source {
+			if !found || celsius > selected {
+				selected = celsius
+			}
+			found = true
+		}
+	}
+
+	t := ensureState(s, "thermal", newThermal)
+	t.Temperatures = temperatures
+
+	// nothing is pushed without a reading, a made up 0 would show up in
+	// the graph and in the history
+	if count == 0 {
+		return fmt.Errorf("no temperature sensor could be read")
+	}
+	var value int
+	switch source {
+	case ThermalMax, "":
+		value = max
+	case ThermalAverage:
+		value = sum / count
+	default:
+		if !found {
+			return fmt.Errorf("temperature sensor %q not found", source)
+		}
+		value = selected
+	}
+	t.Value.Push(value)
+	return nil
+}
@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"time"
 
 	"github.com/lian/gonky/shader"
 	"github.com/lian/gonky/texture"
 	"github.com/lian/gonky/widgets"
+	"github.com/lian/gonky/widgets/graph"
 	"github.com/llgcode/draw2d/draw2dimg"
 	"github.com/llgcode/draw2d/draw2dkit"
 
@@ -19,15 +21,30 @@ type Graphs struct {
 	Redraw  chan bool
 
 	GraphPadding int
-	Stats        *widgets.Stats
+	ShowCpuCores bool
+	// ShowFrequency overlays the average cpu frequency and throttle
+	// events on the thermal graph.
+	ShowFrequency bool
+	Stats         *widgets.Stats
+
+	// Range switches the graphs from the raw values to the downsampled
+	// history of the given span, e.g. time.Hour or 24 * time.Hour.
+	Range time.Duration
+
+	Background color.RGBA
+	Foreground color.RGBA
 }
 
-func New(program *shader.Program, stats *widgets.Stats) *Graphs {
+func New(program *shader.Program, stats *widgets.Stats, x, y, width, height float64) *Graphs {
 	s := &Graphs{
-		Texture:      &texture.Texture{X: 20, Y: 768 - (18 * 2), Width: 300, Height: 200},
-		Redraw:       make(chan bool),
-		GraphPadding: 8,
-		Stats:        stats,
+		Texture:       &texture.Texture{X: x, Y: y, Width: width, Height: height},
+		Redraw:        make(chan bool),
+		GraphPadding:  8,
+		ShowCpuCores:  true,
+		ShowFrequency: true,
+		Stats:         stats,
+		Background:    color.RGBA{0x33, 0x33, 0x33, 0xff},
+		Foreground:    color.RGBA{0x66, 0x66, 0x66, 0xff},
 	}
 	s.Texture.Setup(program)
 	return s
@@ -37,82 +54,78 @@ func (s *Graphs) Render() {
 	data := image.NewRGBA(image.Rect(0, 0, int(s.Texture.Width), int(s.Texture.Height)))
 	gc := draw2dimg.NewGraphicContext(data)
 
-	gc.SetFillColor(color.RGBA{0x33, 0x33, 0x33, 0xff})
+	gc.SetFillColor(s.Background)
 	draw2dkit.Rectangle(gc, 0, 0, s.Texture.Width, s.Texture.Height)
 	gc.Fill()
 
 	//gc.SetFillColor(color.RGBA{0x66, 0x66, 0x66, 0xff})
-	gc.SetStrokeColor(color.RGBA{0x66, 0x66, 0x66, 0xff})
+	gc.SetStrokeColor(s.Foreground)
 	gc.SetLineWidth(1.0)
 
-	s.DrawThermal(gc, data)
-	s.DrawFan(gc, data)
+	stats := s.Stats.Snapshot()
+	s.DrawThermal(gc, data, stats)
+	s.DrawFan(gc, data, stats)
+	if s.ShowCpuCores {
+		s.DrawCpuCores(gc, data, stats)
+	}
 
 	s.Texture.Write(&data.Pix)
 }
 
-func (s *Graphs) DrawThermal(gc *draw2dimg.GraphicContext, data *image.RGBA) {
-	padding := s.GraphPadding
+func (s *Graphs) DrawThermal(gc *draw2dimg.GraphicContext, data *image.RGBA, stats *widgets.Sample) {
 	graphHeight := 40.0
 	yOffset := 0.0
 
-	maxItems := (int(s.Texture.Width) - (font.Width * 5)) / padding
-	start := len(s.Stats.ThermalGraph) - maxItems
-	if start < 0 {
-		start = 0
-	}
-
-	//gc.MoveTo(0, graphHeight+yOffset)
-	var i, value int
-	for i, value = range s.Stats.ThermalGraph[start:] {
-		scaled := graphHeight - float64(int((float64(value-s.Stats.ThermalValueMin)/float64(s.Stats.ThermalValueMax-s.Stats.ThermalValueMin))*graphHeight))
-		height := scaled + float64(yOffset)
-		if i == 0 {
-			gc.MoveTo(float64(i*padding), height)
-		} else {
-			gc.LineTo(float64(i*padding), height)
-		}
-		gc.LineTo(float64(i*padding)+float64(padding), height)
+	thermal := stats.Thermal().Value
+	width := int(s.Texture.Width) - (font.Width * 5)
+	min, max := graph.Bounds(thermal, width, s.GraphPadding, s.Range)
+	graph.Series(gc, thermal, min, max, width, s.GraphPadding, s.Range, yOffset, graphHeight)
+
+	if s.ShowFrequency {
+		freq := stats.CpuFreq()
+		gc.SetStrokeColor(color.RGBA{0x88, 0x55, 0x55, 0xff})
+		graph.Marks(gc, freq.Throttles, width, s.GraphPadding, s.Range, yOffset, graphHeight)
+		gc.SetStrokeColor(color.RGBA{0x55, 0x55, 0x88, 0xff})
+		graph.Series(gc, freq.Average, 0, freq.Max, width, s.GraphPadding, s.Range, yOffset, graphHeight)
+		gc.SetStrokeColor(s.Foreground)
 	}
-	//gc.LineTo(float64(i*padding)+float64(padding), graphHeight+yOffset)
-	//gc.Close()
-	//gc.Fill()
-	gc.Stroke()
 
 	x := (int(s.Texture.Width) - (font.Width * 4))
 	y := int(yOffset + ((graphHeight - font.Height) / 2))
-	font.DrawString(data, x, y, fmt.Sprintf("%dC", s.Stats.ThermalValue), color.RGBA{0x66, 0x66, 0x66, 0xff})
+	font.DrawString(data, x, y, fmt.Sprintf("%dC", thermal.Last()), s.Foreground)
 }
 
-func (s *Graphs) DrawFan(gc *draw2dimg.GraphicContext, data *image.RGBA) {
-	padding := s.GraphPadding
+func (s *Graphs) DrawFan(gc *draw2dimg.GraphicContext, data *image.RGBA, stats *widgets.Sample) {
 	graphHeight := 40.0
 	yOffset := 60.0
 
-	maxItems := (int(s.Texture.Width) - (font.Width * 13)) / padding
-	start := len(s.Stats.FanGraph) - maxItems
-	if start < 0 {
-		start = 0
+	fan := stats.Fan()
+	width := int(s.Texture.Width) - (font.Width * 13)
+	graph.Series(gc, fan.RPM, float64(fan.ValueMin), float64(fan.ValueMax), width, s.GraphPadding, s.Range, yOffset, graphHeight)
+
+	x := (int(s.Texture.Width) - (font.Width * 12))
+	y := int(yOffset + ((graphHeight - font.Height) / 2))
+	text := fmt.Sprintf("%d RPM", fan.RPM.Last())
+	if fan.Level != "" {
+		text += " L" + fan.Level
 	}
+	font.DrawString(data, x, y, text, s.Foreground)
+}
+
+// DrawCpuCores draws the utilisation of every core as its own line.
+func (s *Graphs) DrawCpuCores(gc *draw2dimg.GraphicContext, data *image.RGBA, stats *widgets.Sample) {
+	graphHeight := 40.0
+	yOffset := 120.0
 
-	//gc.MoveTo(0, graphHeight+yOffset)
-	var i, value int
-	for i, value = range s.Stats.FanGraph[start:] {
-		scaled := graphHeight - float64(int((float64(value-s.Stats.FanValueMin)/float64(s.Stats.FanValueMax-s.Stats.FanValueMin))*graphHeight))
-		height := scaled + float64(yOffset)
-		if i == 0 {
-			gc.MoveTo(float64(i*padding), height)
-		} else {
-			gc.LineTo(float64(i*padding), height)
-		}
-		gc.LineTo(float64(i*padding)+float64(padding), height)
+	cpu := stats.Cpu()
+	width := int(s.Texture.Width) - (font.Width * 5)
+	for i, core := range cpu.Cores {
+		gc.SetStrokeColor(graph.Colors[i%len(graph.Colors)])
+		graph.Series(gc, core, 0, 100, width, s.GraphPadding, s.Range, yOffset, graphHeight)
 	}
-	//gc.LineTo(float64(i*padding)+float64(padding), graphHeight+yOffset)
-	//gc.Close()
-	//gc.Fill()
-	gc.Stroke()
+	gc.SetStrokeColor(s.Foreground)
 
-	x := (int(s.Texture.Width) - (font.Width * 12))
+	x := (int(s.Texture.Width) - (font.Width * 4))
 	y := int(yOffset + ((graphHeight - font.Height) / 2))
-	font.DrawString(data, x, y, fmt.Sprintf("%d RPM L%d", s.Stats.FanValue, s.Stats.FanLevel), color.RGBA{0x66, 0x66, 0x66, 0xff})
+	font.DrawString(data, x, y, fmt.Sprintf("%.0f%%", cpu.Total.Last()), s.Foreground)
 }
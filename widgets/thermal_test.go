@@ -0,0 +1,78 @@
+package widgets
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiscoverTemperatureSensors(t *testing.T) {
+	useRoot(t, fixtureRoot)
+	var names []string
+	for _, sensor := range DiscoverTemperatureSensors() {
+		names = append(names, sensor.Key())
+	}
+	want := []string{
+		"hwmon0/coretemp/Package id 0",
+		"hwmon0/coretemp/Core 0",
+		"hwmon1/nvme/Composite",
+		"hwmon2/nvme/Composite",
+		"hwmon3/acpitz/temp1",
+		"hwmon4/thinkpad/temp1",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("sensors %q, want %q", names, want)
+	}
+}
+
+func TestThermalCollector(t *testing.T) {
+	useRoot(t, fixtureRoot)
+	sensors := DiscoverTemperatureSensors()
+
+	tests := []struct {
+		source string
+		want   int
+		err    bool
+	}{
+		{ThermalMax, 52, false},
+		// the unreadable thinkpad sensor is not averaged in
+		{ThermalAverage, (45 + 52 + 38 + 41 + 30) / 5, false},
+		{"coretemp", 52, false},
+		{"coretemp/Package id 0", 45, false},
+		{"nvme", 41, false},
+		{"hwmon1/nvme/Composite", 38, false},
+		{"k10temp/Tctl", 0, true},
+	}
+	for _, test := range tests {
+		c := &ThermalCollector{source: test.source, Sensors: sensors}
+		s := &Sample{}
+		err := c.Collect(s)
+		if (err != nil) != test.err {
+			t.Errorf("%s: error %v", test.source, err)
+		}
+		if test.err {
+			if s.Thermal().Value.Len() != 0 {
+				t.Errorf("%s: pushed %v without the sensor", test.source, s.Thermal().Value.Newest(-1))
+			}
+			continue
+		}
+		if s.Thermal().Value.Last() != test.want {
+			t.Errorf("%s: %d, want %d", test.source, s.Thermal().Value.Last(), test.want)
+		}
+		// both NVMe drives are kept
+		if s.Thermal().Temperatures["hwmon1/nvme/Composite"] != 38 || s.Thermal().Temperatures["hwmon2/nvme/Composite"] != 41 {
+			t.Errorf("%s: temperatures %v", test.source, s.Thermal().Temperatures)
+		}
+	}
+}
+
+func TestThermalCollectorUnreadable(t *testing.T) {
+	useRoot(t, t.TempDir())
+	c := &ThermalCollector{Sensors: []TemperatureSensor{{Chip: "coretemp", Label: "Core 0", Path: "/sys/class/hwmon/hwmon0/temp1_input"}}}
+	s := &Sample{}
+	if err := c.Collect(s); err == nil {
+		t.Error("no error without a readable sensor")
+	}
+	if s.Thermal().Value.Len() != 0 {
+		t.Errorf("pushed %v without a reading", s.Thermal().Value.Newest(-1))
+	}
+}
@@ -0,0 +1,139 @@
+package widgets
+
+import (
+	"sort"
+	"time"
+)
+
+// Bucket aggregates the values pushed during one period of a Tier.
+type Bucket struct {
+	Start time.Time
+	Avg   float64
+	Min   float64
+	Max   float64
+	Count int
+}
+
+func (b *Bucket) add(v float64) {
+	if b.Count == 0 || v < b.Min {
+		b.Min = v
+	}
+	if b.Count == 0 || v > b.Max {
+		b.Max = v
+	}
+	b.Avg += (v - b.Avg) / float64(b.Count+1)
+	b.Count++
+}
+
+// TierSpec describes one downsampled resolution of a Series.
+type TierSpec struct {
+	Resolution time.Duration
+	Retention  time.Duration
+}
+
+// DefaultTiers keeps 1 minute averages for a day and 15 minute averages
+// for 30 days next to the raw values of every series.
+var DefaultTiers = []TierSpec{
+	{Resolution: time.Minute, Retention: 24 * time.Hour},
+	{Resolution: 15 * time.Minute, Retention: 30 * 24 * time.Hour},
+}
+
+// Tier downsamples the values of a series into buckets of a fixed
+// resolution. Completed buckets are only ever appended and dropped from
+// the front, never modified, so a cloned tier can share their backing
+// array with the tier that keeps being written to.
+type Tier struct {
+	TierSpec
+
+	buckets []Bucket
+	current Bucket
+}
+
+func newTier(spec TierSpec) Tier {
+	return Tier{TierSpec: spec}
+}
+
+func (t *Tier) add(at time.Time, v float64) {
+	start := at.Truncate(t.Resolution)
+	if t.current.Count > 0 && !start.Equal(t.current.Start) {
+		t.insert(t.current)
+		t.current = Bucket{}
+	}
+	if t.current.Count == 0 {
+		t.current.Start = start
+	}
+	t.current.add(v)
+}
+
+// insert appends a completed bucket and drops the ones that fell out of
+// the retention.
+func (t *Tier) insert(b Bucket) {
+	t.buckets = append(t.buckets, b)
+
+	cutoff := b.Start.Add(-t.Retention)
+	drop := 0
+	for drop < len(t.buckets) && !t.buckets[drop].Start.After(cutoff) {
+		drop++
+	}
+	t.buckets = t.buckets[drop:]
+}
+
+// Buckets returns a copy of the buckets starting after since, oldest
+// first, including the one that is still being filled.
+func (t *Tier) Buckets(since time.Time) []Bucket {
+	buckets := t.Completed(since)
+	if t.current.Count > 0 && t.current.Start.After(since) {
+		buckets = append(buckets, t.current)
+	}
+	return buckets
+}
+
+// Completed returns a copy of the completed buckets starting after since.
+func (t *Tier) Completed(since time.Time) []Bucket {
+	first := len(t.buckets)
+	for first > 0 && t.buckets[first-1].Start.After(since) {
+		first--
+	}
+	return append([]Bucket(nil), t.buckets[first:]...)
+}
+
+// restore adds a recorded bucket. Buckets are expected to come in time
+// order and before any live value, anything else is merged or inserted
+// into a fresh array so clones sharing the old one are not affected.
+func (t *Tier) restore(b Bucket) {
+	if b.Count == 0 {
+		return
+	}
+
+	if t.current.Count > 0 && b.Start.Equal(t.current.Start) {
+		total := t.current.Count + b.Count
+		t.current.Avg = (t.current.Avg*float64(t.current.Count) + b.Avg*float64(b.Count)) / float64(total)
+		if b.Min < t.current.Min {
+			t.current.Min = b.Min
+		}
+		if b.Max > t.current.Max {
+			t.current.Max = b.Max
+		}
+		t.current.Count = total
+		return
+	}
+
+	if t.current.Count > 0 && b.Start.After(t.current.Start) {
+		return
+	}
+
+	if len(t.buckets) == 0 || b.Start.After(t.buckets[len(t.buckets)-1].Start) {
+		t.insert(b)
+		return
+	}
+
+	i := sort.Search(len(t.buckets), func(i int) bool { return !t.buckets[i].Start.Before(b.Start) })
+	if t.buckets[i].Start.Equal(b.Start) {
+		return
+	}
+	buckets := make([]Bucket, 0, len(t.buckets)+1)
+	buckets = append(buckets, t.buckets[:i]...)
+	buckets = append(buckets, b)
+	buckets = append(buckets, t.buckets[i:]...)
+	t.buckets = buckets
+}
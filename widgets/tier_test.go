@@ -0,0 +1,193 @@
+package widgets
+
+import (
+	"testing"
+	"time"
+)
+
+var tierStart = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+type tierValue struct {
+	after time.Duration
+	v     float64
+}
+
+// bucketStarts returns the starts of buckets as offsets from tierStart.
+func bucketStarts(buckets []Bucket) []time.Duration {
+	var starts []time.Duration
+	for _, b := range buckets {
+		starts = append(starts, b.Start.Sub(tierStart))
+	}
+	return starts
+}
+
+func equalDurations(a, b []time.Duration) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTierAdd(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    []tierValue
+		completed []Bucket
+		current   Bucket
+	}{
+		{"one bucket", []tierValue{{0, 1}, {30 * time.Second, 2}, {59 * time.Second, 6}}, nil,
+			Bucket{Avg: 3, Min: 1, Max: 6, Count: 3}},
+		{"boundary starts a new bucket", []tierValue{{59 * time.Second, 1}, {time.Minute, 2}},
+			[]Bucket{{Avg: 1, Min: 1, Max: 1, Count: 1}},
+			Bucket{Start: tierStart.Add(time.Minute), Avg: 2, Min: 2, Max: 2, Count: 1}},
+		{"unaligned values", []tierValue{{10 * time.Second, 4}, {70 * time.Second, 2}, {130 * time.Second, 3}},
+			[]Bucket{{Avg: 4, Min: 4, Max: 4, Count: 1}, {Start: tierStart.Add(time.Minute), Avg: 2, Min: 2, Max: 2, Count: 1}},
+			Bucket{Start: tierStart.Add(2 * time.Minute), Avg: 3, Min: 3, Max: 3, Count: 1}},
+		{"gaps leave no empty buckets", []tierValue{{0, 1}, {5 * time.Minute, 2}},
+			[]Bucket{{Avg: 1, Min: 1, Max: 1, Count: 1}},
+			Bucket{Start: tierStart.Add(5 * time.Minute), Avg: 2, Min: 2, Max: 2, Count: 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tier := newTier(TierSpec{Resolution: time.Minute, Retention: time.Hour})
+			for _, v := range test.values {
+				tier.add(tierStart.Add(v.after), v.v)
+			}
+			for i := range test.completed {
+				if test.completed[i].Start.IsZero() {
+					test.completed[i].Start = tierStart
+				}
+			}
+			if test.current.Start.IsZero() {
+				test.current.Start = tierStart
+			}
+			equalBuckets(t, "completed", tier.Completed(time.Time{}), test.completed)
+			equalBuckets(t, "all", tier.Buckets(time.Time{}), append(test.completed, test.current))
+		})
+	}
+}
+
+func TestTierRetention(t *testing.T) {
+	tests := []struct {
+		name    string
+		minutes int
+		kept    []time.Duration
+	}{
+		{"within", 3, []time.Duration{0, time.Minute}},
+		{"at the limit", 5, []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute}},
+		{"long after", 10, []time.Duration{6 * time.Minute, 7 * time.Minute, 8 * time.Minute}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tier := newTier(TierSpec{Resolution: time.Minute, Retention: 3 * time.Minute})
+			for i := 0; i < test.minutes; i++ {
+				tier.add(tierStart.Add(time.Duration(i)*time.Minute), float64(i))
+			}
+			// the newest completed bucket is the minute before the last
+			// value, everything older than the retention before it is gone
+			if got := bucketStarts(tier.Completed(time.Time{})); !equalDurations(got, test.kept) {
+				t.Errorf("kept %v, want %v", got, test.kept)
+			}
+		})
+	}
+}
+
+func TestTierRestore(t *testing.T) {
+	bucket := func(minutes int, v float64) Bucket {
+		return Bucket{Start: tierStart.Add(time.Duration(minutes) * time.Minute), Avg: v, Min: v, Max: v, Count: 1}
+	}
+
+	tests := []struct {
+		name    string
+		restore Bucket
+		starts  []time.Duration
+	}{
+		{"in order", bucket(4, 1), []time.Duration{0, 2 * time.Minute, 3 * time.Minute, 4 * time.Minute}},
+		{"out of order", bucket(1, 1), []time.Duration{0, time.Minute, 2 * time.Minute, 3 * time.Minute}},
+		{"before all", bucket(-1, 1), []time.Duration{-time.Minute, 0, 2 * time.Minute, 3 * time.Minute}},
+		{"duplicate", bucket(2, 9), []time.Duration{0, 2 * time.Minute, 3 * time.Minute}},
+		{"empty", Bucket{Start: tierStart.Add(time.Minute)}, []time.Duration{0, 2 * time.Minute, 3 * time.Minute}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tier := newTier(TierSpec{Resolution: time.Minute, Retention: time.Hour})
+			for _, b := range []Bucket{bucket(0, 1), bucket(2, 2), bucket(3, 3)} {
+				tier.restore(b)
+			}
+			original := tier.Completed(time.Time{})
+
+			clone := tier
+			clone.restore(test.restore)
+			if got := bucketStarts(clone.Completed(time.Time{})); !equalDurations(got, test.starts) {
+				t.Errorf("restored %v, want %v", got, test.starts)
+			}
+			// the clone shares the completed buckets with the original
+			equalBuckets(t, "original", tier.Completed(time.Time{}), original)
+		})
+	}
+}
+
+func TestTierRestoreCurrent(t *testing.T) {
+	tier := newTier(TierSpec{Resolution: time.Minute, Retention: time.Hour})
+	tier.add(tierStart.Add(10*time.Second), 4)
+	tier.add(tierStart.Add(20*time.Second), 6)
+
+	// the bucket written before a restart merges into the current one
+	tier.restore(Bucket{Start: tierStart, Avg: 2, Min: 1, Max: 3, Count: 2})
+	// and one after the current is dropped
+	tier.restore(Bucket{Start: tierStart.Add(time.Minute), Avg: 2, Min: 2, Max: 2, Count: 1})
+
+	want := []Bucket{{Start: tierStart, Avg: 3.5, Min: 1, Max: 6, Count: 4}}
+	equalBuckets(t, "buckets", tier.Buckets(time.Time{}), want)
+}
+
+func TestSeriesBuckets(t *testing.T) {
+	s := NewSeries[float64]("cpu", 10)
+	end := tierStart.Add(3 * 24 * time.Hour)
+	for at := tierStart; !at.After(end); at = at.Add(5 * time.Minute) {
+		s.PushAt(at, 1)
+	}
+
+	// values come every 5 minutes, so a 1 minute tier has buckets 5
+	// minutes apart
+	tests := []struct {
+		span time.Duration
+		step time.Duration
+	}{
+		{time.Hour, 5 * time.Minute},
+		{24 * time.Hour, 5 * time.Minute},
+		{25 * time.Hour, 15 * time.Minute},
+		{30 * 24 * time.Hour, 15 * time.Minute},
+		{31 * 24 * time.Hour, 0},
+	}
+	for _, test := range tests {
+		buckets := s.Buckets(test.span)
+		if test.step == 0 {
+			if buckets != nil {
+				t.Errorf("%v: %d buckets beyond every tier", test.span, len(buckets))
+			}
+			continue
+		}
+		covered := test.span
+		if pushed := end.Sub(tierStart); pushed < covered {
+			covered = pushed
+		}
+		if want := int(covered / test.step); len(buckets) < want-1 || len(buckets) > want+1 {
+			t.Errorf("%v: %d buckets, want about %d", test.span, len(buckets), want)
+			continue
+		}
+		for i := 1; i < len(buckets); i++ {
+			if step := buckets[i].Start.Sub(buckets[i-1].Start); step != test.step {
+				t.Errorf("%v: buckets %v apart, want %v", test.span, step, test.step)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package top
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lian/gonky/shader"
+	"github.com/lian/gonky/texture"
+	"github.com/lian/gonky/widgets"
+	"github.com/llgcode/draw2d/draw2dimg"
+	"github.com/llgcode/draw2d/draw2dkit"
+
+	font "github.com/lian/gonky/font/terminus"
+)
+
+// clockTicks is USER_HZ, the unit of the cpu times in /proc/[pid]/stat.
+// It is 100 on every architecture Linux runs gonky on.
+const clockTicks = 100
+
+type Process struct {
+	PID  int
+	Name string
+	// CPU is the utilisation since the previous sample in percent of one
+	// core, RSS the resident memory in bytes.
+	CPU float64
+	RSS uint64
+}
+
+type procTimes struct {
+	name  string
+	ticks uint64
+}
+
+// Top lists the processes using the most CPU and memory.
+type Top struct {
+	Texture *texture.Texture
+	Redraw  chan bool
+
+	Count       int
+	FontPadding int
+	Background  color.RGBA
+	Foreground  color.RGBA
+
+	mu       sync.Mutex
+	byCPU    []Process
+	byMemory []Process
+
+	lastAt time.Time
+	last   map[int]procTimes
+
+	done chan struct{}
+}
+
+func New(program *shader.Program, x, y, width, height float64, count int) *Top {
+	padding := 3
+	// without a size it is 400 wide and as high as the rows need
+	if width == 0 {
+		width = 400
+	}
+	if height == 0 {
+		height = float64((count+1)*font.Height + (2 * padding))
+	}
+	s := &Top{
+		Texture:     &texture.Texture{X: x, Y: y, Width: width, Height: height},
+		Redraw:      make(chan bool),
+		Count:       count,
+		FontPadding: padding,
+		Background:  color.RGBA{0x33, 0x33, 0x33, 0xff},
+		Foreground:  color.RGBA{0x99, 0x99, 0x99, 0xff},
+		last:        map[int]procTimes{},
+		done:        make(chan struct{}),
+	}
+	s.Texture.Setup(program)
+	return s
+}
+
+// Run updates the process list every 5 seconds until Stop is called.
+func (s *Top) Run() {
+	five := time.NewTicker(time.Second * 5)
+	defer five.Stop()
+	for {
+		s.Update()
+		select {
+		case s.Redraw <- true:
+		case <-s.done:
+			return
+		}
+
+		select {
+		case <-five.C:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Stop ends Run.
+func (s *Top) Stop() {
+	close(s.done)
+}
+
+// Update samples every process and keeps the top Count by CPU and RSS.
+// The CPU usage is only known from the second sample of a process on.
+func (s *Top) Update() {
+	dirs, err := widgets.ReadDir("/proc")
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	elapsed := now.Sub(s.lastAt).Seconds()
+	pageSize := uint64(os.Getpagesize())
+
+	current := map[int]procTimes{}
+	var processes []Process
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(dir.Name())
+		if err != nil {
+			continue
+		}
+		times, err := readStat(pid)
+		if err != nil {
+			continue
+		}
+		current[pid] = times
+
+		p := Process{PID: pid, Name: times.name}
+		if pages, err := readResident(pid); err == nil {
+			p.RSS = pages * pageSize
+		}
+		if last, ok := s.last[pid]; ok && elapsed > 0 && times.ticks >= last.ticks {
+			p.CPU = float64(times.ticks-last.ticks) / clockTicks / elapsed * 100
+		}
+		processes = append(processes, p)
+	}
+
+	byCPU := append([]Process(nil), processes...)
+	sort.SliceStable(byCPU, func(i, j int) bool { return byCPU[i].CPU > byCPU[j].CPU })
+	byMemory := processes
+	sort.SliceStable(byMemory, func(i, j int) bool { return byMemory[i].RSS > byMemory[j].RSS })
+
+	if len(byCPU) > s.Count {
+		byCPU = byCPU[:s.Count]
+	}
+	if len(byMemory) > s.Count {
+		byMemory = byMemory[:s.Count]
+	}
+
+	s.last = current
+	s.lastAt = now
+
+	s.mu.Lock()
+	s.byCPU = byCPU
+	s.byMemory = byMemory
+	s.mu.Unlock()
+}
+
+// readStat returns the command name and the user plus system time of a
+// process from /proc/[pid]/stat.
+func readStat(pid int) (procTimes, error) {
+	buf, err := widgets.ReadString(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return procTimes{}, err
+	}
+
+	// the command name is in parentheses and may contain spaces and
+	// parentheses itself, the fields only start after the last one
+	start := strings.IndexByte(buf, '(')
+	end := strings.LastIndexByte(buf, ')')
+	if start < 0 || end < start {
+		return procTimes{}, fmt.Errorf("unexpected format of /proc/%d/stat", pid)
+	}
+	fields := strings.Fields(buf[end+1:])
+	if len(fields) < 13 {
+		return procTimes{}, fmt.Errorf("unexpected format of /proc/%d/stat", pid)
+	}
+
+	// fields starts at field 3 (state), utime and stime are 14 and 15
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return procTimes{}, err
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return procTimes{}, err
+	}
+	return procTimes{name: buf[start+1 : end], ticks: utime + stime}, nil
+}
+
+// readResident returns the resident pages from /proc/[pid]/statm.
+func readResident(pid int) (uint64, error) {
+	buf, err := widgets.ReadString(fmt.Sprintf("/proc/%d/statm", pid))
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(buf)
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("unexpected format of /proc/%d/statm", pid)
+	}
+	return strconv.ParseUint(fields[1], 10, 64)
+}
+
+func (s *Top) Render() {
+	data := image.NewRGBA(image.Rect(0, 0, int(s.Texture.Width), int(s.Texture.Height)))
+	gc := draw2dimg.NewGraphicContext(data)
+
+	gc.SetFillColor(s.Background)
+	draw2dkit.Rectangle(gc, 0, 0, s.Texture.Width, s.Texture.Height)
+	gc.Fill()
+
+	s.mu.Lock()
+	byCPU, byMemory := s.byCPU, s.byMemory
+	s.mu.Unlock()
+
+	textColor := s.Foreground
+	column := int(s.Texture.Width) / 2
+
+	y := s.FontPadding
+	font.DrawString(data, font.Width, y, fmt.Sprintf("%6s %-15s %5s", "PID", "CPU", "%"), textColor)
+	font.DrawString(data, column, y, fmt.Sprintf("%6s %-15s %5s", "PID", "MEM", "MiB"), textColor)
+
+	for i, p := range byCPU {
+		y := s.FontPadding + (i+1)*font.Height
+		font.DrawString(data, font.Width, y, fmt.Sprintf("%6d %-15.15s %5.1f", p.PID, p.Name, p.CPU), textColor)
+	}
+	for i, p := range byMemory {
+		y := s.FontPadding + (i+1)*font.Height
+		font.DrawString(data, column, y, fmt.Sprintf("%6d %-15.15s %5d", p.PID, p.Name, p.RSS/(1024*1024)), textColor)
+	}
+
+	s.Texture.Write(&data.Pix)
+}
@@ -0,0 +1,67 @@
+package top
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lian/gonky/widgets"
+)
+
+// useProc points widgets.Root at a tree with the given /proc/[pid]/stat
+// contents until the test ends.
+func useProc(t *testing.T, stats map[string]string) {
+	t.Helper()
+	root := t.TempDir()
+	for pid, content := range stats {
+		dir := filepath.Join(root, "proc", pid)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "stat"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := widgets.Root
+	t.Setenv("HOST_PROC", "")
+	t.Setenv("HOST_SYS", "")
+	widgets.SetRoot(root)
+	t.Cleanup(func() { widgets.Root = old })
+}
+
+func TestReadStat(t *testing.T) {
+	useProc(t, map[string]string{
+		"1":   "1 (systemd) S 0 1 1 0 -1 4194560 46183 1520311 102 1262 120 380 3402 1418 20 0 1 0 12 22941696 3270 18446744073709551615\n",
+		"42":  "42 (Web Content) S 1 42 42 0 -1 4194560 0 0 0 0 7 3 0 0 20 0 1 0 12 0 0\n",
+		"666": "666 (a) b (c)) R 1 666 666 0 -1 4194560 0 0 0 0 250 50 0 0 20 0 1 0 12 0 0\n",
+		"7":   "7 (kworker/0:1-events) I 2 0 0 0 -1 69238880 0 0 0 0\n",
+		"8":   "8 kthreadd S 0 0 0 0 -1 0 0 0 0 0 1 2 0 0\n",
+	})
+
+	tests := []struct {
+		pid   int
+		name  string
+		ticks uint64
+	}{
+		{1, "systemd", 500},
+		{42, "Web Content", 10},
+		{666, "a) b (c)", 300},
+	}
+	for _, test := range tests {
+		got, err := readStat(test.pid)
+		if err != nil {
+			t.Errorf("pid %d: %v", test.pid, err)
+			continue
+		}
+		if got.name != test.name || got.ticks != test.ticks {
+			t.Errorf("pid %d: %q with %d ticks, want %q with %d", test.pid, got.name, got.ticks, test.name, test.ticks)
+		}
+	}
+
+	// cut off before the times, without parentheses and missing
+	for _, pid := range []int{7, 8, 9} {
+		if got, err := readStat(pid); err == nil {
+			t.Errorf("pid %d: %+v, want an error", pid, got)
+		}
+	}
+}